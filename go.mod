@@ -2,7 +2,10 @@ module snowy_viewer
 
 go 1.21
 
-require github.com/redis/go-redis/v9 v9.7.0
+require (
+	github.com/redis/go-redis/v9 v9.7.0
+	golang.org/x/image v0.18.0
+)
 
 require (
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
@@ -0,0 +1,232 @@
+package bilibili
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"snowy_viewer/internal/fsutil"
+)
+
+// ErrBackfillRunning is returned when a backfill of the UID is in progress
+var ErrBackfillRunning = errors.New("backfill already running")
+
+// ValidUID reports whether uid is a numeric Bilibili user id
+func ValidUID(uid string) bool {
+	if uid == "" || len(uid) > 20 {
+		return false
+	}
+	for _, r := range uid {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ArchiveState tracks how far a backfill has walked back in a feed
+type ArchiveState struct {
+	UID       string    `json:"uid"`
+	Offset    string    `json:"offset"`   // cursor of the next older page
+	Complete  bool      `json:"complete"` // the oldest page was reached
+	Pages     int       `json:"pages"`
+	Running   bool      `json:"running"`
+	LastError string    `json:"lastError,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type archiveFile struct {
+	ArchiveState
+	Items []Dynamic `json:"items"`
+}
+
+// Archive keeps every dynamic seen by a backfill in one JSON file per UID
+// so feeds can be browsed beyond the pages Bilibili serves quickly
+type Archive struct {
+	dir       string
+	pageDelay time.Duration
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// NewArchive stores archives in dir. pageDelay spaces out page requests
+// to stay clear of rate limits.
+func NewArchive(dir string, pageDelay time.Duration) *Archive {
+	return &Archive{
+		dir:       dir,
+		pageDelay: pageDelay,
+		running:   make(map[string]bool),
+	}
+}
+
+func (a *Archive) path(uid string) string {
+	return filepath.Join(a.dir, uid+".json")
+}
+
+func (a *Archive) load(uid string) (*archiveFile, error) {
+	if !ValidUID(uid) {
+		return nil, fmt.Errorf("invalid uid %q", uid)
+	}
+	file := &archiveFile{ArchiveState: ArchiveState{UID: uid}}
+	content, err := os.ReadFile(a.path(uid))
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, file); err != nil {
+		return nil, fmt.Errorf("corrupt archive %s: %v", uid, err)
+	}
+	return file, nil
+}
+
+func (a *Archive) save(file *archiveFile) error {
+	if err := os.MkdirAll(a.dir, 0o755); err != nil {
+		return err
+	}
+	file.UpdatedAt = time.Now()
+	content, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(a.path(file.UID), content, 0o644)
+}
+
+// merge adds items to the archive, replacing older copies, newest first
+func (file *archiveFile) merge(items []Dynamic) {
+	index := make(map[string]int, len(file.Items))
+	for i, d := range file.Items {
+		index[d.ID] = i
+	}
+	for _, d := range items {
+		// Pins move between pages; the archive keeps chronological order
+		d.Pinned = false
+		if i, ok := index[d.ID]; ok {
+			file.Items[i] = d
+			continue
+		}
+		index[d.ID] = len(file.Items)
+		file.Items = append(file.Items, d)
+	}
+	sort.SliceStable(file.Items, func(i, j int) bool {
+		return file.Items[i].PublishedAt > file.Items[j].PublishedAt
+	})
+}
+
+// Items returns a page of the archived dynamics of uid, newest first
+func (a *Archive) Items(uid string, offset, limit int) ([]Dynamic, ArchiveState, int, error) {
+	a.mu.Lock()
+	file, err := a.load(uid)
+	running := a.running[uid]
+	a.mu.Unlock()
+	if err != nil {
+		return nil, ArchiveState{}, 0, err
+	}
+	file.Running = running
+	total := len(file.Items)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return file.Items[offset:end], file.ArchiveState, total, nil
+}
+
+// State returns the backfill state of uid
+func (a *Archive) State(uid string) (ArchiveState, error) {
+	_, state, _, err := a.Items(uid, 0, 0)
+	return state, err
+}
+
+// Backfill merges the newest page and then walks up to maxPages older
+// pages into the archive, resuming where the previous backfill stopped.
+// Progress is saved after every page.
+func (a *Archive) Backfill(c *Client, uid string, maxPages int) error {
+	a.mu.Lock()
+	if a.running[uid] {
+		a.mu.Unlock()
+		return ErrBackfillRunning
+	}
+	a.running[uid] = true
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.running, uid)
+		a.mu.Unlock()
+	}()
+
+	file, err := a.load(uid)
+	if err != nil {
+		return err
+	}
+
+	fail := func(err error) error {
+		file.LastError = err.Error()
+		a.save(file)
+		return err
+	}
+
+	// Always pick up new posts first
+	feed, err := c.fetchFeedPage(uid, "")
+	if err != nil {
+		return fail(err)
+	}
+	file.merge(feed.Items)
+	if file.Offset == "" && !file.Complete {
+		file.Offset = feed.Offset
+		file.Complete = !feed.HasMore
+	}
+	file.LastError = ""
+	if err := a.save(file); err != nil {
+		return err
+	}
+
+	for page := 0; page < maxPages && !file.Complete && file.Offset != ""; page++ {
+		time.Sleep(a.pageDelay)
+		feed, err := c.fetchFeedPage(uid, file.Offset)
+		if err != nil {
+			return fail(err)
+		}
+		file.merge(feed.Items)
+		file.Pages++
+		file.Offset = feed.Offset
+		file.Complete = !feed.HasMore || len(feed.Items) == 0
+		if err := a.save(file); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Bilibili archive %s: %d dynamics, complete=%v\n", uid, len(file.Items), file.Complete)
+	return nil
+}
+
+// fetchFeedPage fetches and normalises one page of a feed
+func (c *Client) fetchFeedPage(uid, offset string) (*Feed, error) {
+	data, status, err := c.FetchDynamicPage(uid, offset)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("Bilibili API status %d", status)
+	}
+	return ParseFeed(data)
+}
+
+// Archive returns the local feed archive
+func (c *Client) Archive() *Archive {
+	return c.archive
+}
+
+// Backfill walks up to maxPages older pages of uid into the archive
+func (c *Client) Backfill(uid string, maxPages int) error {
+	return c.archive.Backfill(c, uid, maxPages)
+}
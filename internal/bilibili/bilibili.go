@@ -4,10 +4,10 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"path/filepath"
 	"sort"
@@ -45,45 +45,93 @@ var mixinKeyEncTab = []int{
 	36, 20, 34, 44, 52,
 }
 
+// apiTimeout bounds an API request, including its wait for the rate limit
+const apiTimeout = 10 * time.Second
+
 // Client handles Bilibili API requests
 type Client struct {
 	httpClient   *http.Client
+	upstream     *guardedTransport
+	jar          *sessionJar
 	wbiKeys      WbiKeys
 	wbiMutex     sync.RWMutex
+	resetMutex   sync.Mutex
+	lastReset    time.Time
 	cache        *cache.Cache
-	sessData     string
-	cookieString string
+	credentials  *CredentialPool
+	imagePolicy  ImagePolicy
+	imageClient  *http.Client
+	archive      *Archive
+	timelineUIDs []string
+}
+
+// Options configures a Client
+type Options struct {
+	// SessData and CookieString seed the credential pool as one account
+	SessData     string
+	CookieString string
+	// CredentialsFile persists the credential pool
+	CredentialsFile string
+	// ImagePolicy restricts what FetchImage may fetch
+	ImagePolicy ImagePolicy
+	// ArchiveDir stores feeds walked by Backfill
+	ArchiveDir string
+	// TimelineUIDs are the accounts merged by FetchTimeline
+	TimelineUIDs []string
+	// Limits throttles API requests per host
+	Limits Limits
 }
 
 // NewClient creates a new Bilibili client
-func NewClient(c *cache.Cache, sessData, cookieString string) *Client {
-	jar, _ := cookiejar.New(nil)
+func NewClient(c *cache.Cache, opts Options) *Client {
+	imagePolicy := opts.ImagePolicy
+	if len(imagePolicy.AllowedHosts) == 0 {
+		imagePolicy.AllowedHosts = DefaultImageHosts
+	}
+	jar := newSessionJar()
+	upstream := newGuardedTransport(http.DefaultTransport, opts.Limits)
 	client := &Client{
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-			Jar:     jar,
+			Timeout:   apiTimeout,
+			Jar:       jar,
+			Transport: upstream,
 		},
+		upstream:     upstream,
+		jar:          jar,
 		cache:        c,
-		sessData:     sessData,
-		cookieString: cookieString,
+		credentials:  NewCredentialPool(opts.CredentialsFile, seedCredentials(opts)),
+		imagePolicy:  imagePolicy,
+		imageClient:  newImageHTTPClient(imagePolicy),
+		archive:      NewArchive(opts.ArchiveDir, 2*time.Second),
+		timelineUIDs: opts.TimelineUIDs,
 	}
 
 	// Initial cookie fetch
 	go func() {
-		req, _ := http.NewRequest("GET", "https://www.bilibili.com/", nil)
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-		resp, err := client.httpClient.Do(req)
-		if err == nil {
-			defer resp.Body.Close()
-			fmt.Println("Initialized Bilibili cookies")
-		} else {
+		if err := client.bootstrapCookies(); err != nil {
 			fmt.Printf("Failed to init cookies: %v\n", err)
+		} else {
+			fmt.Println("Initialized Bilibili cookies")
 		}
 	}()
 
 	return client
 }
 
+// seedCredentials turns the configured cookies into the default account
+func seedCredentials(opts Options) []*Credential {
+	if opts.SessData == "" && opts.CookieString == "" {
+		return nil
+	}
+	cred, err := newCredential("default", opts.CookieString, opts.SessData)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return nil
+	}
+	cred.Seed = seedFingerprint(opts.CookieString, opts.SessData)
+	return []*Credential{cred}
+}
+
 func (c *Client) getWbiKeys() (WbiKeys, error) {
 	c.wbiMutex.RLock()
 	if time.Since(c.wbiKeys.lastUpdateTime) < 1*time.Hour && c.wbiKeys.Mixin != "" {
@@ -173,16 +221,33 @@ func (c *Client) signWbi(params url.Values) (string, error) {
 	return params.Encode(), nil
 }
 
-// FetchDynamic fetches user's dynamic feed with caching
+// FetchDynamic fetches user's dynamic feed with caching. Concurrent
+// requests for a UID share one upstream call and an expired feed is served
+// while it is refreshed in the background.
 func (c *Client) FetchDynamic(uid string) ([]byte, int, error) {
-	// Check cache first
-	if data, ok := c.cache.GetDynamic(uid); ok {
-		return data, http.StatusOK, nil
+	return c.FetchDynamicPage(uid, "")
+}
+
+// FetchDynamicPage fetches the page of a user's feed that starts at the
+// offset cursor returned with the previous page. An empty offset requests
+// the newest page.
+func (c *Client) FetchDynamicPage(uid, offset string) ([]byte, int, error) {
+	entry, err := c.cache.FetchDynamicPage(uid, offset, func() (*cache.Entry, error) {
+		return c.loadDynamic(uid, offset)
+	})
+	if err != nil {
+		return nil, entryStatus(entry, http.StatusBadGateway), err
 	}
+	return entry.Data, entry.Status, nil
+}
 
+func (c *Client) loadDynamic(uid, offset string) (*cache.Entry, error) {
 	// Prepare request
 	params := url.Values{}
 	params.Set("host_mid", uid)
+	if offset != "" {
+		params.Set("offset", offset)
+	}
 	params.Set("platform", "web")
 	params.Set("web_location", "0.0")
 	params.Set("dm_img_list", "[]")
@@ -190,13 +255,82 @@ func (c *Client) FetchDynamic(uid string) ([]byte, int, error) {
 	params.Set("dm_cover_img_str", "QU5HTEUgKEFNRCwgQU1EIFJhZGVvbiA3ODBNIEdyYXBoaWNzICgweDAwMDAxNUJGKSBEaXJlY3QzRDExIHZzXzVfMCBwc181XzAsIEQzRDExKUdvb2dsZSBJbmMuIChBTU")
 	params.Set("features", "itemOpusStyle,listOnlyfans,opusBigCover,onlyfansVote,forwardListHidden,decorationCard,commentsNewVersion,onlyfansAssetsV2,ugcDelete,onlyfansQaCard,avatarAutoTheme,sunflowerStyle,cardsEnhance,eva3CardOpus,eva3CardVideo,eva3CardComment,eva3CardUser")
 
-	signedQuery, err := c.signWbi(params)
+	body, status, err := c.getSigned("https://api.bilibili.com/x/polymer/web-dynamic/v1/feed/space", params, "https://space.bilibili.com/"+uid+"/dynamic")
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("WBI Sign Error: %v", err)
+		// Serve the last page that got through rather than the rejection
+		if entry, ok := c.lastGood(dynamicKey(uid, offset), err); ok {
+			return entry, nil
+		}
+		return &cache.Entry{Status: status}, err
+	}
+
+	// Cache success response only
+	entry := &cache.Entry{Data: body, Status: status, NoStore: true}
+	if code, ok := responseCode(body); ok && code == 0 && status == http.StatusOK {
+		entry.NoStore = false
+		c.cache.SetLastGood(dynamicKey(uid, offset), body)
 	}
+	return entry, nil
+}
 
-	targetUrl := "https://api.bilibili.com/x/polymer/web-dynamic/v1/feed/space?" + signedQuery
+// lastGood returns the last successful response for key when err means
+// Bilibili is refusing or we are holding back requests
+func (c *Client) lastGood(key string, err error) (*cache.Entry, bool) {
+	if !errors.Is(err, ErrRiskControl) && !errors.Is(err, ErrCircuitOpen) && !errors.Is(err, ErrRateLimited) {
+		return nil, false
+	}
+	data, ok := c.cache.LastGood(key)
+	if !ok {
+		return nil, false
+	}
+	fmt.Printf("Serving last good %s: %v\n", key, err)
+	return &cache.Entry{Data: data, ContentType: "application/json", Status: http.StatusOK, NoStore: true}, true
+}
 
+// UpstreamStatus reports the rate limiter and circuit breaker of every
+// Bilibili host contacted so far
+func (c *Client) UpstreamStatus() []HostStatus {
+	return c.upstream.Status()
+}
+
+// upstreamErrorStatus returns the status to report for a failed request
+func upstreamErrorStatus(err error) int {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrRateLimited) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+// dynamicKey identifies a page of a feed in the last good store
+func dynamicKey(uid, offset string) string {
+	if offset == "" {
+		return "dynamic:" + uid
+	}
+	return "dynamic:" + uid + ":" + offset
+}
+
+// get requests a Bilibili API URL with the browser headers and cookies the
+// web client sends, using the next account of the credential pool. A
+// request rejected for an expired session is retried once with the next
+// account. The returned status is the one to report on error.
+func (c *Client) get(targetUrl, referer string) ([]byte, int, error) {
+	for attempt := 0; ; attempt++ {
+		cred := c.credentials.acquire()
+		body, status, err := c.getAs(cred, targetUrl, referer)
+		if err != nil || cred == nil {
+			return body, status, err
+		}
+		if code, ok := responseCode(body); !ok || code != codeNotLoggedIn {
+			return body, status, nil
+		}
+		c.credentials.markExpired(cred)
+		if attempt == 1 {
+			return body, status, nil
+		}
+	}
+}
+
+func (c *Client) getAs(cred *Credential, targetUrl, referer string) ([]byte, int, error) {
 	req, err := http.NewRequest("GET", targetUrl, nil)
 	if err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("Request Creation Error: %v", err)
@@ -204,75 +338,88 @@ func (c *Client) FetchDynamic(uid string) ([]byte, int, error) {
 
 	// Set Headers
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	req.Header.Set("Referer", "https://space.bilibili.com/"+uid+"/dynamic")
-	req.Header.Set("Origin", "https://space.bilibili.com")
+	req.Header.Set("Referer", referer)
+	if u, err := url.Parse(referer); err == nil {
+		req.Header.Set("Origin", u.Scheme+"://"+u.Host)
+	}
 	req.Header.Set("Accept", "application/json, text/plain, */*")
 	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
 
 	// Add Cookies
-	if c.sessData != "" {
-		req.AddCookie(&http.Cookie{Name: "SESSDATA", Value: c.sessData})
-	}
-	if c.cookieString != "" {
-		req.Header.Set("Cookie", c.cookieString)
+	if cred != nil {
+		addCookies(req, c.credentials.cookies(cred))
 	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, http.StatusBadGateway, fmt.Errorf("Bilibili API Error: %v", err)
+		return nil, upstreamErrorStatus(err), fmt.Errorf("Bilibili API Error: %w", err)
 	}
 	defer resp.Body.Close()
+	if cred != nil {
+		c.credentials.update(cred, resp)
+	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("Failed to read response")
 	}
-
-	// Cache success response
-	if resp.StatusCode == http.StatusOK {
-		var check map[string]interface{}
-		if err := json.Unmarshal(body, &check); err == nil {
-			if code, ok := check["code"].(float64); ok && code == 0 {
-				c.cache.SetDynamic(uid, body)
-			}
-		}
-	}
-
 	return body, resp.StatusCode, nil
 }
 
-// FetchImage fetches an image with caching
+// FetchImage fetches an image with caching. Concurrent requests for a URL
+// share one upstream call. URLs outside the image policy are rejected
+// with an *ImageError.
 func (c *Client) FetchImage(imageUrl string) ([]byte, string, int, error) {
-	// Check cache first
-	if data, contentType, ok := c.cache.GetImage(imageUrl); ok {
-		return data, contentType, http.StatusOK, nil
+	u, err := c.imagePolicy.ValidateImageURL(imageUrl)
+	if err != nil {
+		return nil, "", imageErrorStatus(err, http.StatusBadRequest), err
 	}
+	imageUrl = u.String()
 
+	entry, err := c.cache.FetchImage(imageUrl, func() (*cache.Entry, error) {
+		return c.loadImage(imageUrl)
+	})
+	if err != nil {
+		return nil, "", entryStatus(entry, http.StatusBadGateway), err
+	}
+	return entry.Data, entry.ContentType, entry.Status, nil
+}
+
+func (c *Client) loadImage(imageUrl string) (*cache.Entry, error) {
 	req, err := http.NewRequest("GET", imageUrl, nil)
 	if err != nil {
-		return nil, "", http.StatusBadRequest, fmt.Errorf("Invalid URL")
+		return &cache.Entry{Status: http.StatusBadRequest}, fmt.Errorf("Invalid URL")
 	}
 
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 	req.Header.Set("Referer", "https://www.bilibili.com/")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.imageClient.Do(req)
 	if err != nil {
-		return nil, "", http.StatusBadGateway, fmt.Errorf("Failed to fetch image")
+		var imageErr *ImageError
+		if errors.As(err, &imageErr) {
+			return &cache.Entry{Status: imageErr.Status}, imageErr
+		}
+		return &cache.Entry{Status: http.StatusBadGateway}, fmt.Errorf("Failed to fetch image")
 	}
 	defer resp.Body.Close()
 
-	data, err := io.ReadAll(resp.Body)
+	data, contentType, err := c.imagePolicy.readImage(resp)
 	if err != nil {
-		return nil, "", http.StatusInternalServerError, fmt.Errorf("Failed to read image")
+		return &cache.Entry{Status: imageErrorStatus(err, http.StatusInternalServerError)}, err
 	}
 
-	contentType := resp.Header.Get("Content-Type")
+	return &cache.Entry{
+		Data:        data,
+		ContentType: contentType,
+		Status:      http.StatusOK,
+	}, nil
+}
 
-	// Cache success response
-	if resp.StatusCode == http.StatusOK {
-		c.cache.SetImage(imageUrl, data, contentType)
+// entryStatus returns the status code a failed load reported
+func entryStatus(entry *cache.Entry, fallback int) int {
+	if entry != nil && entry.Status != 0 {
+		return entry.Status
 	}
-
-	return data, contentType, resp.StatusCode, nil
+	return fallback
 }
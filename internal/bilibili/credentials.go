@@ -0,0 +1,389 @@
+package bilibili
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"snowy_viewer/internal/fsutil"
+)
+
+// codeNotLoggedIn is the API code for requests without a valid session
+const codeNotLoggedIn = -101
+
+// sessionCookies are the cookies Bilibili refreshes on a logged in session
+var sessionCookies = map[string]bool{
+	"SESSDATA":          true,
+	"bili_jct":          true,
+	"DedeUserID":        true,
+	"DedeUserID__ckMd5": true,
+	"sid":               true,
+}
+
+// Credential is one logged in Bilibili account
+type Credential struct {
+	Name      string            `json:"name"`
+	Cookies   map[string]string `json:"cookies"`
+	Expired   bool              `json:"expired"`
+	ExpiredAt *time.Time        `json:"expiredAt,omitempty"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	// Seed fingerprints the environment value the credential came from
+	Seed string `json:"seed,omitempty"`
+}
+
+// CredentialInput is a credential as submitted by an admin. Cookie is a
+// Cookie header; SessData is shorthand for a lone SESSDATA cookie.
+type CredentialInput struct {
+	Name     string `json:"name"`
+	Cookie   string `json:"cookie"`
+	SessData string `json:"sessdata"`
+}
+
+// CredentialStatus describes a credential without revealing its cookies
+type CredentialStatus struct {
+	Name      string     `json:"name"`
+	Cookies   []string   `json:"cookies"` // cookie names
+	SessData  string     `json:"sessdata,omitempty"`
+	Expired   bool       `json:"expired"`
+	ExpiredAt *time.Time `json:"expiredAt,omitempty"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	LastUsed  *time.Time `json:"lastUsed,omitempty"`
+	Requests  int64      `json:"requests"`
+}
+
+// parseCookieHeader splits a Cookie header into its name/value pairs
+func parseCookieHeader(header string) map[string]string {
+	req := http.Request{Header: http.Header{"Cookie": {header}}}
+	cookies := make(map[string]string)
+	for _, c := range req.Cookies() {
+		cookies[c.Name] = c.Value
+	}
+	return cookies
+}
+
+// newCredential builds a credential from a Cookie header and a SESSDATA
+// value; SESSDATA is added to the header cookies unless it already has one
+func newCredential(name, cookieHeader, sessData string) (*Credential, error) {
+	cookies := parseCookieHeader(cookieHeader)
+	if sessData != "" && cookies["SESSDATA"] == "" {
+		cookies["SESSDATA"] = sessData
+	}
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf("credential %q has no cookies", name)
+	}
+	return &Credential{Name: name, Cookies: cookies, UpdatedAt: time.Now()}, nil
+}
+
+// CredentialPool rotates requests between several accounts, retiring the
+// ones whose session expired. Cookies refreshed by Bilibili are written
+// back to the pool file so they survive restarts.
+type CredentialPool struct {
+	path   string
+	saveMu sync.Mutex // orders writes of the pool file
+
+	mu       sync.Mutex
+	creds    []*Credential
+	next     int
+	lastUsed map[string]time.Time
+	requests map[string]int64
+}
+
+// NewCredentialPool loads the pool from path and merges the seed
+// credentials into it. A seed replaces the saved credential of the same
+// name once its value changes; until then the saved one, with the cookies
+// Bilibili refreshed since, is kept.
+func NewCredentialPool(path string, seed []*Credential) *CredentialPool {
+	p := &CredentialPool{
+		path:     path,
+		creds:    seed,
+		lastUsed: make(map[string]time.Time),
+		requests: make(map[string]int64),
+	}
+	if path == "" {
+		return p
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p
+	}
+	var creds []*Credential
+	if err == nil {
+		err = json.Unmarshal(content, &creds)
+	}
+	if err != nil {
+		fmt.Printf("Warning: ignoring Bilibili credentials file: %v\n", err)
+		return p
+	}
+	var changed bool
+	p.creds, changed = mergeSeed(creds, seed)
+	if changed {
+		p.persist()
+	}
+	return p
+}
+
+// mergeSeed adds the seed credentials to creds, replacing the ones whose
+// seed changed, and reports whether creds changed
+func mergeSeed(creds, seed []*Credential) ([]*Credential, bool) {
+	changed := false
+	for _, s := range seed {
+		i := 0
+		for i < len(creds) && creds[i].Name != s.Name {
+			i++
+		}
+		switch {
+		case i == len(creds):
+			creds = append(creds, s)
+			changed = true
+		case creds[i].Seed != s.Seed:
+			fmt.Printf("Bilibili credential %q changed in the environment, replacing the saved one\n", s.Name)
+			creds[i] = s
+			changed = true
+		}
+	}
+	return creds, changed
+}
+
+// seedFingerprint identifies the environment value of a seed credential
+// without storing it twice
+func seedFingerprint(cookieHeader, sessData string) string {
+	sum := sha256.Sum256([]byte(cookieHeader + "\n" + sessData))
+	return hex.EncodeToString(sum[:8])
+}
+
+// acquire returns the next credential that has not expired, or nil to send
+// the request anonymously
+func (p *CredentialPool) acquire() *Credential {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for range p.creds {
+		cred := p.creds[p.next%len(p.creds)]
+		p.next = (p.next + 1) % len(p.creds)
+		if !cred.Expired {
+			p.lastUsed[cred.Name] = time.Now()
+			p.requests[cred.Name]++
+			return cred
+		}
+	}
+	return nil
+}
+
+// addCookies adds cookies to a request in a stable order
+func addCookies(req *http.Request, cookies map[string]string) {
+	names := make([]string, 0, len(cookies))
+	for name := range cookies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		req.AddCookie(&http.Cookie{Name: name, Value: cookies[name]})
+	}
+}
+
+// cookies returns a copy of the cookies of cred
+func (p *CredentialPool) cookies(cred *Credential) map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cookies := make(map[string]string, len(cred.Cookies))
+	for name, value := range cred.Cookies {
+		cookies[name] = value
+	}
+	return cookies
+}
+
+// update stores session cookies Bilibili set on a response made with cred.
+// A deleted SESSDATA means the session was logged out.
+func (p *CredentialPool) update(cred *Credential, resp *http.Response) {
+	changed := false
+	p.mu.Lock()
+	for _, c := range resp.Cookies() {
+		if !sessionCookies[c.Name] {
+			continue
+		}
+		if c.MaxAge < 0 || (!c.Expires.IsZero() && c.Expires.Before(time.Now())) {
+			if c.Name == "SESSDATA" && !cred.Expired {
+				p.expire(cred)
+				changed = true
+			}
+			continue
+		}
+		if cred.Cookies[c.Name] != c.Value {
+			cred.Cookies[c.Name] = c.Value
+			cred.UpdatedAt = time.Now()
+			changed = true
+		}
+	}
+	p.mu.Unlock()
+	if changed {
+		p.persist()
+	}
+}
+
+// markExpired retires a credential whose session the API rejected
+func (p *CredentialPool) markExpired(cred *Credential) {
+	p.mu.Lock()
+	if cred.Expired {
+		p.mu.Unlock()
+		return
+	}
+	p.expire(cred)
+	p.mu.Unlock()
+	p.persist()
+}
+
+// expire marks cred as expired; p.mu must be held
+func (p *CredentialPool) expire(cred *Credential) {
+	now := time.Now()
+	cred.Expired = true
+	cred.ExpiredAt = &now
+	fmt.Printf("Bilibili credential %q expired\n", cred.Name)
+}
+
+// Replace swaps every credential for the submitted ones
+func (p *CredentialPool) Replace(inputs []CredentialInput) error {
+	creds := make([]*Credential, 0, len(inputs))
+	names := make(map[string]bool, len(inputs))
+	for i, in := range inputs {
+		if in.Name == "" {
+			in.Name = fmt.Sprintf("account-%d", i+1)
+		}
+		if names[in.Name] {
+			return fmt.Errorf("duplicate credential %q", in.Name)
+		}
+		names[in.Name] = true
+		cred, err := newCredential(in.Name, in.Cookie, in.SessData)
+		if err != nil {
+			return err
+		}
+		creds = append(creds, cred)
+	}
+	p.mu.Lock()
+	for _, cred := range creds {
+		for _, c := range p.creds {
+			if c.Name == cred.Name {
+				cred.Seed = c.Seed
+			}
+		}
+	}
+	p.creds = creds
+	p.next = 0
+	p.mu.Unlock()
+	return p.persist()
+}
+
+// Put adds a credential or replaces the one with the same name
+func (p *CredentialPool) Put(in CredentialInput) error {
+	if in.Name == "" {
+		return errors.New("missing credential name")
+	}
+	cred, err := newCredential(in.Name, in.Cookie, in.SessData)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	replaced := false
+	for i, c := range p.creds {
+		if c.Name == in.Name {
+			// Edits outlive restarts until the environment value changes
+			cred.Seed = c.Seed
+			p.creds[i] = cred
+			replaced = true
+		}
+	}
+	if !replaced {
+		p.creds = append(p.creds, cred)
+	}
+	p.mu.Unlock()
+	return p.persist()
+}
+
+// Remove deletes a credential by name
+func (p *CredentialPool) Remove(name string) bool {
+	p.mu.Lock()
+	removed := false
+	for i, c := range p.creds {
+		if c.Name == name {
+			p.creds = append(p.creds[:i], p.creds[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	p.mu.Unlock()
+	if removed {
+		p.persist()
+	}
+	return removed
+}
+
+// Status lists the credentials with their cookies masked
+func (p *CredentialPool) Status() []CredentialStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	statuses := make([]CredentialStatus, 0, len(p.creds))
+	for _, cred := range p.creds {
+		s := CredentialStatus{
+			Name:      cred.Name,
+			Cookies:   make([]string, 0, len(cred.Cookies)),
+			SessData:  maskSecret(cred.Cookies["SESSDATA"]),
+			Expired:   cred.Expired,
+			ExpiredAt: cred.ExpiredAt,
+			UpdatedAt: cred.UpdatedAt,
+			Requests:  p.requests[cred.Name],
+		}
+		for name := range cred.Cookies {
+			s.Cookies = append(s.Cookies, name)
+		}
+		sort.Strings(s.Cookies)
+		if t, ok := p.lastUsed[cred.Name]; ok {
+			s.LastUsed = &t
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// maskSecret keeps the last four characters of a secret
+func maskSecret(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+	return "****" + secret[len(secret)-4:]
+}
+
+// persist writes the pool file
+func (p *CredentialPool) persist() error {
+	if p.path == "" {
+		return nil
+	}
+	p.saveMu.Lock()
+	defer p.saveMu.Unlock()
+	p.mu.Lock()
+	content, err := json.MarshalIndent(p.creds, "", "  ")
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(p.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(p.path, content, 0o600); err != nil {
+		fmt.Printf("Warning: failed to save Bilibili credentials: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// Credentials returns the credential pool
+func (c *Client) Credentials() *CredentialPool {
+	return c.credentials
+}
@@ -0,0 +1,438 @@
+package bilibili
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Normalised dynamic types
+const (
+	DynamicVideo   = "video"
+	DynamicImage   = "image"
+	DynamicText    = "text"
+	DynamicForward = "forward"
+	DynamicArticle = "article"
+	DynamicLive    = "live"
+	DynamicOther   = "other"
+)
+
+// Normalised rich text node types
+const (
+	RichTextText  = "text"
+	RichTextAt    = "at"
+	RichTextTopic = "topic"
+	RichTextLink  = "link"
+	RichTextEmoji = "emoji"
+	RichTextOther = "other"
+)
+
+// Feed is one page of a user's dynamics
+type Feed struct {
+	Items   []Dynamic `json:"items"`
+	HasMore bool      `json:"hasMore"`
+	Offset  string    `json:"offset"`
+}
+
+// Dynamic is a post independent of the card layout Bilibili renders it with
+type Dynamic struct {
+	ID          string         `json:"id"`
+	Type        string         `json:"type"`
+	RawType     string         `json:"rawType"`
+	Pinned      bool           `json:"pinned"`
+	Author      Author         `json:"author"`
+	Text        string         `json:"text"`
+	RichText    []RichTextNode `json:"richText"`
+	Images      []Image        `json:"images"`
+	Video       *Video         `json:"video,omitempty"`
+	Article     *Article       `json:"article,omitempty"`
+	Title       string         `json:"title,omitempty"`
+	Forward     *Dynamic       `json:"forward,omitempty"`
+	Stats       Stats          `json:"stats"`
+	PublishedAt int64          `json:"publishedAt"` // unix seconds
+	URL         string         `json:"url"`
+}
+
+type Author struct {
+	MID    int64  `json:"mid"`
+	Name   string `json:"name"`
+	Face   string `json:"face"`
+	Action string `json:"action,omitempty"`
+}
+
+type RichTextNode struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	URL   string `json:"url,omitempty"`
+	Emoji string `json:"emoji,omitempty"`
+	RID   string `json:"rid,omitempty"`
+}
+
+type Image struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type Video struct {
+	AID         string `json:"aid"`
+	BVID        string `json:"bvid"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Cover       string `json:"cover"`
+	Duration    string `json:"duration"`
+	Play        string `json:"play"`
+	Danmaku     string `json:"danmaku"`
+	URL         string `json:"url"`
+}
+
+type Article struct {
+	ID     int64    `json:"id"`
+	Title  string   `json:"title"`
+	Desc   string   `json:"desc"`
+	Covers []string `json:"covers"`
+	URL    string   `json:"url"`
+}
+
+type Stats struct {
+	Comment int64 `json:"comment"`
+	Forward int64 `json:"forward"`
+	Like    int64 `json:"like"`
+}
+
+// flexInt accepts numbers and numeric strings, both of which Bilibili uses
+// for the same fields
+type flexInt int64
+
+func (n *flexInt) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 || string(data) == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(string(data), 10, 64)
+	if err == nil {
+		*n = flexInt(v)
+		return nil
+	}
+	// Large counters are abbreviated, e.g. "1.2万"
+	if text, ok := strings.CutSuffix(string(data), "万"); ok {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			*n = flexInt(math.Round(f * 10000))
+			return nil
+		}
+	}
+	*n = 0
+	return nil
+}
+
+// flexString accepts strings and numbers
+type flexString string
+
+func (s *flexString) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var v string
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		*s = flexString(v)
+		return nil
+	}
+	if string(data) == "null" {
+		*s = ""
+		return nil
+	}
+	*s = flexString(data)
+	return nil
+}
+
+// Raw feed/space response
+
+type rawFeedResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		HasMore bool         `json:"has_more"`
+		Items   []rawDynamic `json:"items"`
+		Offset  flexString   `json:"offset"`
+	} `json:"data"`
+}
+
+type rawDynamic struct {
+	IDStr string `json:"id_str"`
+	Type  string `json:"type"`
+	Basic struct {
+		JumpURL string `json:"jump_url"`
+	} `json:"basic"`
+	Modules struct {
+		Author struct {
+			MID       flexInt `json:"mid"`
+			Name      string  `json:"name"`
+			Face      string  `json:"face"`
+			PubTs     flexInt `json:"pub_ts"`
+			PubAction string  `json:"pub_action"`
+		} `json:"module_author"`
+		Dynamic struct {
+			Desc  *rawRichText `json:"desc"`
+			Major *rawMajor    `json:"major"`
+		} `json:"module_dynamic"`
+		Stat struct {
+			Comment struct {
+				Count flexInt `json:"count"`
+			} `json:"comment"`
+			Forward struct {
+				Count flexInt `json:"count"`
+			} `json:"forward"`
+			Like struct {
+				Count flexInt `json:"count"`
+			} `json:"like"`
+		} `json:"module_stat"`
+		Tag *struct {
+			Text string `json:"text"`
+		} `json:"module_tag"`
+	} `json:"modules"`
+	Orig *rawDynamic `json:"orig"`
+}
+
+type rawRichText struct {
+	Text  string            `json:"text"`
+	Nodes []rawRichTextNode `json:"rich_text_nodes"`
+}
+
+type rawRichTextNode struct {
+	Type    string     `json:"type"`
+	Text    string     `json:"text"`
+	RID     flexString `json:"rid"`
+	JumpURL string     `json:"jump_url"`
+	Emoji   *struct {
+		IconURL string `json:"icon_url"`
+	} `json:"emoji"`
+}
+
+type rawPicture struct {
+	Src    string  `json:"src"`
+	URL    string  `json:"url"`
+	Width  flexInt `json:"width"`
+	Height flexInt `json:"height"`
+}
+
+type rawMajor struct {
+	Type    string `json:"type"`
+	Archive *struct {
+		AID          flexString `json:"aid"`
+		BVID         string     `json:"bvid"`
+		Title        string     `json:"title"`
+		Desc         string     `json:"desc"`
+		Cover        string     `json:"cover"`
+		DurationText string     `json:"duration_text"`
+		JumpURL      string     `json:"jump_url"`
+		Stat         struct {
+			Play    flexString `json:"play"`
+			Danmaku flexString `json:"danmaku"`
+		} `json:"stat"`
+	} `json:"archive"`
+	Draw *struct {
+		Items []rawPicture `json:"items"`
+	} `json:"draw"`
+	Opus *struct {
+		Title   string       `json:"title"`
+		Summary *rawRichText `json:"summary"`
+		Pics    []rawPicture `json:"pics"`
+	} `json:"opus"`
+	Article *struct {
+		ID      flexInt  `json:"id"`
+		Title   string   `json:"title"`
+		Desc    string   `json:"desc"`
+		Covers  []string `json:"covers"`
+		JumpURL string   `json:"jump_url"`
+	} `json:"article"`
+}
+
+// APIError is a non-zero code in a Bilibili API response
+type APIError struct {
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Bilibili API code %d: %s", e.Code, e.Message)
+}
+
+// ParseFeed maps a raw feed/space response to the normalised model
+func ParseFeed(body []byte) (*Feed, error) {
+	var raw rawFeedResponse
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("decode dynamic feed: %v", err)
+	}
+	if raw.Code != 0 {
+		return nil, &APIError{Code: raw.Code, Message: raw.Message}
+	}
+	feed := &Feed{
+		Items:   make([]Dynamic, 0, len(raw.Data.Items)),
+		HasMore: raw.Data.HasMore,
+		Offset:  string(raw.Data.Offset),
+	}
+	for i := range raw.Data.Items {
+		feed.Items = append(feed.Items, raw.Data.Items[i].normalize())
+	}
+	return feed, nil
+}
+
+func (r *rawDynamic) normalize() Dynamic {
+	m := r.Modules
+	d := Dynamic{
+		ID:      r.IDStr,
+		Type:    dynamicType(r.Type),
+		RawType: r.Type,
+		Pinned:  m.Tag != nil && m.Tag.Text == "置顶",
+		Author: Author{
+			MID:    int64(m.Author.MID),
+			Name:   m.Author.Name,
+			Face:   m.Author.Face,
+			Action: m.Author.PubAction,
+		},
+		RichText:    []RichTextNode{},
+		Images:      []Image{},
+		PublishedAt: int64(m.Author.PubTs),
+		URL:         absoluteURL(r.Basic.JumpURL),
+		Stats: Stats{
+			Comment: int64(m.Stat.Comment.Count),
+			Forward: int64(m.Stat.Forward.Count),
+			Like:    int64(m.Stat.Like.Count),
+		},
+	}
+	if d.URL == "" && d.ID != "" {
+		d.URL = "https://t.bilibili.com/" + d.ID
+	}
+	if m.Dynamic.Desc != nil {
+		d.setText(m.Dynamic.Desc)
+	}
+
+	if major := m.Dynamic.Major; major != nil {
+		switch {
+		case major.Archive != nil:
+			a := major.Archive
+			d.Video = &Video{
+				AID:         string(a.AID),
+				BVID:        a.BVID,
+				Title:       a.Title,
+				Description: a.Desc,
+				Cover:       a.Cover,
+				Duration:    a.DurationText,
+				Play:        string(a.Stat.Play),
+				Danmaku:     string(a.Stat.Danmaku),
+				URL:         absoluteURL(a.JumpURL),
+			}
+			if d.Video.URL == "" && a.BVID != "" {
+				d.Video.URL = "https://www.bilibili.com/video/" + a.BVID
+			}
+		case major.Draw != nil:
+			d.Images = pictures(major.Draw.Items)
+		case major.Opus != nil:
+			o := major.Opus
+			d.Title = o.Title
+			d.Images = pictures(o.Pics)
+			if d.Text == "" && o.Summary != nil {
+				d.setText(o.Summary)
+			}
+		case major.Article != nil:
+			a := major.Article
+			d.Article = &Article{
+				ID:     int64(a.ID),
+				Title:  a.Title,
+				Desc:   a.Desc,
+				Covers: a.Covers,
+				URL:    absoluteURL(a.JumpURL),
+			}
+			if d.Article.Covers == nil {
+				d.Article.Covers = []string{}
+			}
+		}
+		// Image posts are rendered as opus cards by the new web layout
+		if d.Type == DynamicOther && len(d.Images) > 0 {
+			d.Type = DynamicImage
+		}
+	}
+
+	if r.Orig != nil {
+		orig := r.Orig.normalize()
+		d.Forward = &orig
+	}
+	return d
+}
+
+// setText copies a rich text block
+func (d *Dynamic) setText(rt *rawRichText) {
+	d.Text = rt.Text
+	for _, n := range rt.Nodes {
+		node := RichTextNode{
+			Type: richTextType(n.Type),
+			Text: n.Text,
+			URL:  absoluteURL(n.JumpURL),
+			RID:  string(n.RID),
+		}
+		if n.Emoji != nil {
+			node.Emoji = n.Emoji.IconURL
+		}
+		d.RichText = append(d.RichText, node)
+	}
+}
+
+func pictures(raw []rawPicture) []Image {
+	images := make([]Image, 0, len(raw))
+	for _, p := range raw {
+		u := p.Src
+		if u == "" {
+			u = p.URL
+		}
+		images = append(images, Image{URL: u, Width: int(p.Width), Height: int(p.Height)})
+	}
+	return images
+}
+
+// dynamicType maps DYNAMIC_TYPE_* to the normalised types
+func dynamicType(raw string) string {
+	switch raw {
+	case "DYNAMIC_TYPE_AV", "DYNAMIC_TYPE_UGC_SEASON", "DYNAMIC_TYPE_PGC", "DYNAMIC_TYPE_PGC_UNION":
+		return DynamicVideo
+	case "DYNAMIC_TYPE_DRAW":
+		return DynamicImage
+	case "DYNAMIC_TYPE_WORD":
+		return DynamicText
+	case "DYNAMIC_TYPE_FORWARD":
+		return DynamicForward
+	case "DYNAMIC_TYPE_ARTICLE":
+		return DynamicArticle
+	case "DYNAMIC_TYPE_LIVE_RCMD", "DYNAMIC_TYPE_LIVE":
+		return DynamicLive
+	}
+	return DynamicOther
+}
+
+// richTextType maps RICH_TEXT_NODE_TYPE_* to the normalised node types
+func richTextType(raw string) string {
+	switch raw {
+	case "RICH_TEXT_NODE_TYPE_TEXT":
+		return RichTextText
+	case "RICH_TEXT_NODE_TYPE_AT":
+		return RichTextAt
+	case "RICH_TEXT_NODE_TYPE_TOPIC":
+		return RichTextTopic
+	case "RICH_TEXT_NODE_TYPE_WEB", "RICH_TEXT_NODE_TYPE_BV", "RICH_TEXT_NODE_TYPE_AV", "RICH_TEXT_NODE_TYPE_OGV_SEASON":
+		return RichTextLink
+	case "RICH_TEXT_NODE_TYPE_EMOJI":
+		return RichTextEmoji
+	}
+	return RichTextOther
+}
+
+// absoluteURL turns protocol-relative Bilibili links into https URLs
+func absoluteURL(u string) string {
+	if strings.HasPrefix(u, "//") {
+		return "https:" + u
+	}
+	return u
+}
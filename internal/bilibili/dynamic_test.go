@@ -0,0 +1,299 @@
+package bilibili
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// parseFixture parses a recorded feed/space response from testdata
+func parseFixture(t *testing.T, name string) *Feed {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, err := ParseFeed(body)
+	if err != nil {
+		t.Fatalf("ParseFeed(%s): %v", name, err)
+	}
+	return feed
+}
+
+func TestParseFeedDraw(t *testing.T) {
+	feed := parseFixture(t, "draw.json")
+	if !feed.HasMore || feed.Offset != "861234567890123456" || len(feed.Items) != 1 {
+		t.Fatalf("page = hasMore %v offset %q items %d", feed.HasMore, feed.Offset, len(feed.Items))
+	}
+
+	d := feed.Items[0]
+	want := Dynamic{
+		ID:      "861234567890123456",
+		Type:    DynamicImage,
+		RawType: "DYNAMIC_TYPE_DRAW",
+		Pinned:  true,
+		Author: Author{
+			MID:  13148307,
+			Name: "世界计划多彩舞台",
+			Face: "https://i1.hdslb.com/bfs/face/5d7b0c2a.jpg",
+		},
+		Text:     "新卡面公开！",
+		RichText: []RichTextNode{{Type: RichTextText, Text: "新卡面公开！"}},
+		Images: []Image{
+			{URL: "https://i0.hdslb.com/bfs/new_dyn/a1b2c3.jpg", Width: 1920, Height: 1080},
+			{URL: "https://i0.hdslb.com/bfs/new_dyn/d4e5f6.png", Width: 1536, Height: 2048},
+		},
+		Stats:       Stats{Comment: 128, Forward: 16, Like: 2048},
+		PublishedAt: 1700460000,
+		URL:         "https://www.bilibili.com/opus/861234567890123456",
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("dynamic =\n%+v\nwant\n%+v", d, want)
+	}
+}
+
+func TestParseFeedOpus(t *testing.T) {
+	feed := parseFixture(t, "opus.json")
+	if len(feed.Items) != 3 {
+		t.Fatalf("got %d items, want 3", len(feed.Items))
+	}
+
+	image := feed.Items[0]
+	if image.Type != DynamicImage || image.Title != "四月活动" || image.Text != "活动预告" {
+		t.Errorf("opus image = type %q title %q text %q", image.Type, image.Title, image.Text)
+	}
+	if len(image.Images) != 1 || image.Images[0].URL != "https://i0.hdslb.com/bfs/new_dyn/opus1.jpg" {
+		t.Errorf("opus images = %+v", image.Images)
+	}
+
+	text := feed.Items[1]
+	if text.Type != DynamicText || text.Title != "" || text.Text != "维护延长通知" {
+		t.Errorf("opus text = type %q title %q text %q", text.Type, text.Title, text.Text)
+	}
+	if len(text.Images) != 0 || text.Images == nil {
+		t.Errorf("opus text images = %#v, want empty slice", text.Images)
+	}
+
+	// Unknown types with pictures are image posts in the new layout
+	other := feed.Items[2]
+	if other.Type != DynamicImage || other.RawType != "DYNAMIC_TYPE_COMMON_SQUARE" {
+		t.Errorf("opus other = type %q raw %q", other.Type, other.RawType)
+	}
+	if other.URL != "https://t.bilibili.com/912345678901233000" {
+		t.Errorf("fallback url = %q", other.URL)
+	}
+}
+
+func TestParseFeedArchive(t *testing.T) {
+	feed := parseFixture(t, "archive.json")
+	if len(feed.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Items))
+	}
+
+	d := feed.Items[0]
+	if d.Type != DynamicVideo || d.Author.Action != "投稿了视频" {
+		t.Errorf("type %q action %q", d.Type, d.Author.Action)
+	}
+	want := &Video{
+		AID:         "1051234567",
+		BVID:        "BV1Xx4y1k7Ab",
+		Title:       "【3DMV】新曲",
+		Description: "3DMV 公开",
+		Cover:       "http://i0.hdslb.com/bfs/archive/cover1.jpg",
+		Duration:    "03:21",
+		Play:        "25.3万",
+		Danmaku:     "1.1万",
+		URL:         "https://www.bilibili.com/video/BV1Xx4y1k7Ab/",
+	}
+	if !reflect.DeepEqual(d.Video, want) {
+		t.Errorf("video =\n%+v\nwant\n%+v", d.Video, want)
+	}
+
+	// Numeric ids and counters, and no jump url
+	v := feed.Items[1].Video
+	if v == nil {
+		t.Fatal("second item has no video")
+	}
+	if v.AID != "1050000001" || v.Play != "3456" || v.Danmaku != "12" {
+		t.Errorf("aid %q play %q danmaku %q", v.AID, v.Play, v.Danmaku)
+	}
+	if v.URL != "https://www.bilibili.com/video/BV1ab411c7Cd" {
+		t.Errorf("fallback video url = %q", v.URL)
+	}
+}
+
+func TestParseFeedForward(t *testing.T) {
+	feed := parseFixture(t, "forward.json")
+	d := feed.Items[0]
+	if d.Type != DynamicForward || d.Text != "转发动态" {
+		t.Errorf("type %q text %q", d.Type, d.Text)
+	}
+	if d.URL != "https://t.bilibili.com/880011223344556677" {
+		t.Errorf("url = %q", d.URL)
+	}
+
+	orig := d.Forward
+	if orig == nil {
+		t.Fatal("forward has no original")
+	}
+	if orig.ID != "879999999999999999" || orig.Type != DynamicImage || orig.Author.MID != 404145357 {
+		t.Errorf("original = id %q type %q mid %d", orig.ID, orig.Type, orig.Author.MID)
+	}
+	if orig.Text != "生日快乐！" || len(orig.Images) != 1 {
+		t.Errorf("original text %q images %d", orig.Text, len(orig.Images))
+	}
+	if orig.Forward != nil {
+		t.Error("original has a nested forward")
+	}
+}
+
+func TestParseFeedArticle(t *testing.T) {
+	feed := parseFixture(t, "article.json")
+	if len(feed.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Items))
+	}
+	// A numeric offset is kept verbatim
+	if feed.Offset != "890000000000000000" {
+		t.Errorf("offset = %q", feed.Offset)
+	}
+
+	want := &Article{
+		ID:    28394712,
+		Title: "版本更新公告",
+		Desc:  "本次更新内容如下",
+		Covers: []string{
+			"https://i0.hdslb.com/bfs/article/c1.jpg",
+			"https://i0.hdslb.com/bfs/article/c2.jpg",
+		},
+		URL: "https://www.bilibili.com/read/cv28394712/",
+	}
+	if d := feed.Items[0]; d.Type != DynamicArticle || !reflect.DeepEqual(d.Article, want) {
+		t.Errorf("article = type %q\n%+v\nwant\n%+v", d.Type, d.Article, want)
+	}
+
+	a := feed.Items[1].Article
+	if a == nil || a.ID != 28390000 || a.Covers == nil || len(a.Covers) != 0 {
+		t.Errorf("article without covers = %#v", a)
+	}
+}
+
+func TestParseFeedLive(t *testing.T) {
+	feed := parseFixture(t, "live.json")
+	d := feed.Items[0]
+	if d.Type != DynamicLive || d.RawType != "DYNAMIC_TYPE_LIVE_RCMD" {
+		t.Errorf("type %q raw %q", d.Type, d.RawType)
+	}
+	if d.Video != nil || d.Article != nil || len(d.Images) != 0 {
+		t.Errorf("live card has content: %+v", d)
+	}
+	if d.URL != "https://live.bilibili.com/21396545" || feed.Offset != "" {
+		t.Errorf("url %q offset %q", d.URL, feed.Offset)
+	}
+}
+
+func TestParseFeedRichText(t *testing.T) {
+	feed := parseFixture(t, "richtext.json")
+	want := []RichTextNode{
+		{Type: RichTextTopic, Text: "#多彩舞台#", URL: "https://search.bilibili.com/all?keyword=%E5%A4%9A%E5%BD%A9%E8%88%9E%E5%8F%B0"},
+		{Type: RichTextText, Text: " 感谢 "},
+		{Type: RichTextAt, Text: "@初音未来_Crypton", RID: "404145357"},
+		{Type: RichTextEmoji, Text: "[爱心]", Emoji: "https://i0.hdslb.com/bfs/emote/heart.png"},
+		{Type: RichTextLink, Text: "新曲MV", URL: "https://www.bilibili.com/video/BV1Xx4y1k7Ab", RID: "1051234567"},
+		{Type: RichTextLink, Text: "网页链接", URL: "https://pjsekai.sega.jp/"},
+		{Type: RichTextOther, Text: "互动抽奖", RID: "9876543"},
+	}
+	if got := feed.Items[0].RichText; !reflect.DeepEqual(got, want) {
+		t.Errorf("rich text =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseFeedNumberStrings(t *testing.T) {
+	feed := parseFixture(t, "numbers.json")
+	if feed.Offset != "905000000000000000" {
+		t.Errorf("offset = %q", feed.Offset)
+	}
+	d := feed.Items[0]
+	if d.Author.MID != 13148307 || d.PublishedAt != 1707000000 {
+		t.Errorf("mid %d publishedAt %d", d.Author.MID, d.PublishedAt)
+	}
+	if d.Stats != (Stats{Comment: 0, Forward: 0, Like: 12000}) {
+		t.Errorf("stats = %+v", d.Stats)
+	}
+	// Pictures without src fall back to url
+	want := []Image{{URL: "https://i0.hdslb.com/bfs/new_dyn/fallback.jpg", Width: 1920, Height: 1080}}
+	if !reflect.DeepEqual(d.Images, want) {
+		t.Errorf("images = %+v", d.Images)
+	}
+}
+
+func TestParseFeedAPIError(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "risk_control.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseFeed(body)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != -352 {
+		t.Fatalf("err = %v, want APIError -352", err)
+	}
+
+	if _, err := ParseFeed([]byte("<html>")); err == nil || errors.As(err, &apiErr) {
+		t.Errorf("invalid JSON: err = %v", err)
+	}
+}
+
+func TestFlexInt(t *testing.T) {
+	tests := []struct {
+		in   string
+		want flexInt
+	}{
+		{`12`, 12},
+		{`"12"`, 12},
+		{`-3`, -3},
+		{`null`, 0},
+		{`""`, 0},
+		{`"1.2万"`, 12000},
+		{`"1.15万"`, 11500},
+		{`"2.3万"`, 23000},
+		{`"3万"`, 30000},
+		{`"--"`, 0},
+		{`"1.5亿"`, 0},
+	}
+	for _, tt := range tests {
+		var n flexInt
+		if err := json.Unmarshal([]byte(tt.in), &n); err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if n != tt.want {
+			t.Errorf("%s = %d, want %d", tt.in, n, tt.want)
+		}
+	}
+}
+
+func TestFlexString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want flexString
+	}{
+		{`"BV1Xx4y1k7Ab"`, "BV1Xx4y1k7Ab"},
+		{`"万"`, "万"},
+		{`1051234567`, "1051234567"},
+		{`905000000000000000`, "905000000000000000"},
+		{`null`, ""},
+		{`""`, ""},
+	}
+	for _, tt := range tests {
+		var s flexString
+		if err := json.Unmarshal([]byte(tt.in), &s); err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if s != tt.want {
+			t.Errorf("%s = %q, want %q", tt.in, s, tt.want)
+		}
+	}
+}
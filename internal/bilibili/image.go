@@ -0,0 +1,169 @@
+package bilibili
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultImageHosts are the Bilibili image CDNs the image proxy may fetch
+// from. Subdomains are allowed as well.
+var DefaultImageHosts = []string{"hdslb.com", "biliimg.com"}
+
+// DefaultImageMaxBytes limits the size of a proxied image
+const DefaultImageMaxBytes = 20 << 20
+
+// ImagePolicy restricts what the image proxy may fetch
+type ImagePolicy struct {
+	// AllowedHosts lists the allowed domains, including their subdomains
+	AllowedHosts []string
+	// MaxBytes limits the response body; <= 0 uses DefaultImageMaxBytes
+	MaxBytes int64
+}
+
+// ImageError is a rejected image request. Status is the HTTP status the
+// proxy should answer with.
+type ImageError struct {
+	Status  int
+	Message string
+}
+
+func (e *ImageError) Error() string { return e.Message }
+
+func rejectImage(status int, format string, args ...interface{}) error {
+	return &ImageError{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+// cgnatBlock is the shared address space of carrier-grade NAT, which
+// net.IP.IsPrivate does not cover
+var cgnatBlock = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP reports whether ip is a globally routable unicast address
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || cgnatBlock.Contains(ip))
+}
+
+// checkURL validates the scheme, port and host of an image URL
+func (p ImagePolicy) checkURL(u *url.URL) error {
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return rejectImage(http.StatusBadRequest, "Unsupported URL scheme")
+	}
+	if u.User != nil {
+		return rejectImage(http.StatusBadRequest, "Credentials in URL are not allowed")
+	}
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		return rejectImage(http.StatusBadRequest, "Non-standard port is not allowed")
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return rejectImage(http.StatusBadRequest, "Invalid URL")
+	}
+	for _, allowed := range p.AllowedHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return nil
+		}
+	}
+	return rejectImage(http.StatusForbidden, "Host not allowed: %s", host)
+}
+
+// ValidateImageURL parses an image URL and checks it against the policy
+func (p ImagePolicy) ValidateImageURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() {
+		return nil, rejectImage(http.StatusBadRequest, "Invalid URL")
+	}
+	if err := p.checkURL(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// newImageHTTPClient returns a client that only connects to public
+// addresses, checked after DNS resolution so rebinding cannot point an
+// allowed host at the internal network, and that re-validates redirects
+func newImageHTTPClient(policy ImagePolicy) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return rejectImage(http.StatusForbidden, "Address not allowed: %s", host)
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		// A proxy would connect on our behalf and bypass the address check
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 15 * time.Second,
+		MaxIdleConnsPerHost:   8,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return rejectImage(http.StatusBadGateway, "Too many redirects")
+			}
+			return policy.checkURL(req.URL)
+		},
+	}
+}
+
+// readImage reads an upstream image response, enforcing the size limit and
+// an image content type. Upstream errors are reported without their body,
+// which may be anything the host chose to send.
+func (p ImagePolicy) readImage(resp *http.Response) ([]byte, string, error) {
+	if resp.StatusCode != http.StatusOK {
+		status := http.StatusBadGateway
+		if resp.StatusCode == http.StatusNotFound {
+			status = http.StatusNotFound
+		}
+		return nil, "", rejectImage(status, "Upstream returned %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(strings.ToLower(contentType), "image/") {
+		return nil, "", rejectImage(http.StatusBadGateway, "Upstream did not return an image")
+	}
+	maxBytes := p.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultImageMaxBytes
+	}
+	if resp.ContentLength > maxBytes {
+		return nil, "", rejectImage(http.StatusBadGateway, "Image too large")
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, "", rejectImage(http.StatusBadGateway, "Image too large")
+	}
+	return data, contentType, nil
+}
+
+// imageErrorStatus returns the status of a rejected request wrapped in err
+func imageErrorStatus(err error, fallback int) int {
+	var imageErr *ImageError
+	if errors.As(err, &imageErr) {
+		return imageErr.Status
+	}
+	return fallback
+}
@@ -0,0 +1,49 @@
+package bilibili
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func imageResponse(status int, contentType, body string) *http.Response {
+	return &http.Response{
+		StatusCode:    status,
+		Status:        http.StatusText(status),
+		Header:        http.Header{"Content-Type": []string{contentType}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+func TestReadImage(t *testing.T) {
+	var p ImagePolicy
+	data, contentType, err := p.readImage(imageResponse(http.StatusOK, "image/png", "png"))
+	if err != nil || string(data) != "png" || contentType != "image/png" {
+		t.Errorf("readImage = %q, %q, %v", data, contentType, err)
+	}
+
+	tests := []struct {
+		status      int
+		contentType string
+		want        int
+	}{
+		{http.StatusNotFound, "image/png", http.StatusNotFound},
+		{http.StatusForbidden, "text/html", http.StatusBadGateway},
+		{http.StatusInternalServerError, "image/png", http.StatusBadGateway},
+		{http.StatusPartialContent, "image/png", http.StatusBadGateway},
+		{http.StatusOK, "text/html", http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		data, _, err := p.readImage(imageResponse(tt.status, tt.contentType, "<html>upstream page</html>"))
+		var imageErr *ImageError
+		if !errors.As(err, &imageErr) || imageErr.Status != tt.want {
+			t.Errorf("%d %s: err = %v, want status %d", tt.status, tt.contentType, err, tt.want)
+		}
+		if data != nil || strings.Contains(err.Error(), "upstream page") {
+			t.Errorf("%d %s: upstream body relayed", tt.status, tt.contentType)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package bilibili
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCircuitOpen is returned without contacting a host whose circuit
+	// breaker opened after repeated failures
+	ErrCircuitOpen = errors.New("upstream circuit open")
+	// ErrRateLimited is returned when a request would wait too long for
+	// the rate limit of its host
+	ErrRateLimited = errors.New("upstream rate limit exceeded")
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open" // letting one probe request through
+)
+
+// Limits configures the per-host rate limiter and circuit breaker
+type Limits struct {
+	// RatePerMinute is the sustained request rate per host; <= 0 disables
+	// rate limiting
+	RatePerMinute int
+	// Burst is the number of requests allowed at once
+	Burst int
+	// MaxWait is the longest a request queues for the rate limit. It is
+	// capped at half the API timeout, which also covers the wait, so a
+	// queued request is rejected with ErrRateLimited rather than timing out.
+	MaxWait time.Duration
+	// FailureThreshold failures within FailureWindow open the breaker;
+	// <= 0 disables the breaker
+	FailureThreshold int
+	FailureWindow    time.Duration
+	// Cooldown is how long the breaker stays open before probing again
+	Cooldown time.Duration
+}
+
+// Defaults of the Limits fields that cannot be disabled
+const (
+	DefaultBurst         = 10
+	DefaultMaxWait       = 3 * time.Second
+	DefaultFailureWindow = 1 * time.Minute
+	DefaultCooldown      = 1 * time.Minute
+)
+
+func (l Limits) withDefaults() Limits {
+	if l.Burst <= 0 {
+		l.Burst = DefaultBurst
+	}
+	if l.MaxWait <= 0 {
+		l.MaxWait = DefaultMaxWait
+	}
+	if l.MaxWait > apiTimeout/2 {
+		l.MaxWait = apiTimeout / 2
+	}
+	if l.FailureWindow <= 0 {
+		l.FailureWindow = DefaultFailureWindow
+	}
+	if l.Cooldown <= 0 {
+		l.Cooldown = DefaultCooldown
+	}
+	return l
+}
+
+// HostStatus reports the limiter and breaker of one upstream host
+type HostStatus struct {
+	Host      string     `json:"host"`
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"` // within the failure window
+	OpenedAt  *time.Time `json:"openedAt,omitempty"`
+	RetryAt   *time.Time `json:"retryAt,omitempty"`
+	Tokens    float64    `json:"tokens"`
+	Requests  int64      `json:"requests"`
+	Rejected  int64      `json:"rejected"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// hostGuard is the token bucket and circuit breaker of one host
+type hostGuard struct {
+	limits Limits
+
+	mu        sync.Mutex
+	tokens    float64
+	refilled  time.Time
+	state     string
+	failures  []time.Time
+	openedAt  time.Time
+	probing   bool
+	requests  int64
+	rejected  int64
+	lastError string
+}
+
+func newHostGuard(limits Limits) *hostGuard {
+	return &hostGuard{
+		limits:   limits,
+		tokens:   float64(limits.Burst),
+		refilled: time.Now(),
+		state:    BreakerClosed,
+	}
+}
+
+// acquire checks the breaker and takes a token, returning how long the
+// caller must wait before sending
+func (g *hostGuard) acquire(now time.Time) (time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch g.state {
+	case BreakerOpen:
+		if now.Sub(g.openedAt) < g.limits.Cooldown {
+			g.rejected++
+			return 0, ErrCircuitOpen
+		}
+		g.state = BreakerHalfOpen
+		g.probing = false
+		fallthrough
+	case BreakerHalfOpen:
+		if g.probing {
+			g.rejected++
+			return 0, ErrCircuitOpen
+		}
+		g.probing = true
+	}
+
+	if g.limits.RatePerMinute <= 0 {
+		g.requests++
+		return 0, nil
+	}
+	rate := float64(g.limits.RatePerMinute) / 60
+	g.tokens = math.Min(float64(g.limits.Burst), g.tokens+now.Sub(g.refilled).Seconds()*rate)
+	g.refilled = now
+
+	// Tokens go negative to queue requests behind each other
+	wait := time.Duration(0)
+	if g.tokens < 1 {
+		wait = time.Duration((1 - g.tokens) / rate * float64(time.Second))
+	}
+	if wait > g.limits.MaxWait {
+		g.rejected++
+		if g.state == BreakerHalfOpen {
+			g.probing = false
+		}
+		return 0, ErrRateLimited
+	}
+	g.tokens--
+	g.requests++
+	return wait, nil
+}
+
+// record updates the breaker with the outcome of a request
+func (g *hostGuard) record(now time.Time, err error) {
+	if g.limits.FailureThreshold <= 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err == nil {
+		if g.state == BreakerHalfOpen {
+			g.state = BreakerClosed
+			g.failures = nil
+		}
+		g.probing = false
+		return
+	}
+
+	g.lastError = err.Error()
+	if g.state == BreakerHalfOpen {
+		g.open(now)
+		return
+	}
+	g.failures = append(g.recentFailures(now), now)
+	if g.state == BreakerClosed && len(g.failures) >= g.limits.FailureThreshold {
+		g.open(now)
+	}
+}
+
+// cancel returns the token and probe of a request that was never sent
+func (g *hostGuard) cancel() {
+	g.mu.Lock()
+	if g.limits.RatePerMinute > 0 {
+		g.tokens++
+	}
+	g.probing = false
+	g.mu.Unlock()
+}
+
+func (g *hostGuard) open(now time.Time) {
+	g.state = BreakerOpen
+	g.openedAt = now
+	g.probing = false
+}
+
+// recentFailures drops failures outside the window; g.mu must be held
+func (g *hostGuard) recentFailures(now time.Time) []time.Time {
+	cutoff := now.Add(-g.limits.FailureWindow)
+	i := 0
+	for i < len(g.failures) && g.failures[i].Before(cutoff) {
+		i++
+	}
+	return g.failures[i:]
+}
+
+func (g *hostGuard) status(host string, now time.Time) HostStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := HostStatus{
+		Host:      host,
+		State:     g.state,
+		Failures:  len(g.recentFailures(now)),
+		Tokens:    math.Max(0, g.tokens),
+		Requests:  g.requests,
+		Rejected:  g.rejected,
+		LastError: g.lastError,
+	}
+	if g.state != BreakerClosed {
+		openedAt := g.openedAt
+		retryAt := openedAt.Add(g.limits.Cooldown)
+		s.OpenedAt, s.RetryAt = &openedAt, &retryAt
+	}
+	return s
+}
+
+// guardedTransport applies a hostGuard per request host
+type guardedTransport struct {
+	base   http.RoundTripper
+	limits Limits
+
+	mu     sync.Mutex
+	guards map[string]*hostGuard
+}
+
+func newGuardedTransport(base http.RoundTripper, limits Limits) *guardedTransport {
+	return &guardedTransport{
+		base:   base,
+		limits: limits.withDefaults(),
+		guards: make(map[string]*hostGuard),
+	}
+}
+
+func (t *guardedTransport) guard(host string) *hostGuard {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g, ok := t.guards[host]
+	if !ok {
+		g = newHostGuard(t.limits)
+		t.guards[host] = g
+	}
+	return g
+}
+
+func (t *guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	g := t.guard(req.URL.Host)
+	wait, err := g.acquire(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			g.cancel()
+			return nil, req.Context().Err()
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil:
+		g.record(time.Now(), err)
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusPreconditionFailed || resp.StatusCode == http.StatusTooManyRequests:
+		g.record(time.Now(), errors.New(resp.Status))
+	default:
+		g.record(time.Now(), nil)
+	}
+	return resp, err
+}
+
+// reportFailure counts a failure the transport cannot see, such as a risk
+// control rejection sent with status 200
+func (t *guardedTransport) reportFailure(host string, err error) {
+	t.guard(host).record(time.Now(), err)
+}
+
+// Status reports every host contacted so far
+func (t *guardedTransport) Status() []HostStatus {
+	t.mu.Lock()
+	hosts := make([]string, 0, len(t.guards))
+	for host := range t.guards {
+		hosts = append(hosts, host)
+	}
+	t.mu.Unlock()
+	sort.Strings(hosts)
+
+	now := time.Now()
+	statuses := make([]HostStatus, 0, len(hosts))
+	for _, host := range hosts {
+		statuses = append(statuses, t.guard(host).status(host, now))
+	}
+	return statuses
+}
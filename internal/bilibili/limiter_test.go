@@ -0,0 +1,60 @@
+package bilibili
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaxWaitIsShorterThanTimeout(t *testing.T) {
+	for _, maxWait := range []time.Duration{0, time.Second, apiTimeout, time.Minute} {
+		l := Limits{MaxWait: maxWait}.withDefaults()
+		if l.MaxWait <= 0 || l.MaxWait > apiTimeout/2 {
+			t.Errorf("MaxWait %s became %s, want at most %s", maxWait, l.MaxWait, apiTimeout/2)
+		}
+	}
+}
+
+func TestQueuedRequestIsRateLimited(t *testing.T) {
+	// One token per second: the tenth queued request would wait 10s
+	g := newHostGuard(Limits{RatePerMinute: 60, Burst: 1}.withDefaults())
+	now := time.Now()
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		_, err = g.acquire(now)
+	}
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("err = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestCancelledWaitReturnsToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	tr := newGuardedTransport(http.DefaultTransport, Limits{RatePerMinute: 60, Burst: 1})
+
+	// The first request takes the only token, the second has to queue
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	if _, err := tr.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+
+	g := tr.guard(req.URL.Host)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.tokens < -0.5 {
+		t.Errorf("tokens = %.2f after a cancelled wait, want about 0", g.tokens)
+	}
+}
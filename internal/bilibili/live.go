@@ -0,0 +1,208 @@
+package bilibili
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"snowy_viewer/internal/cache"
+)
+
+// Live room states
+const (
+	LiveStatusLive    = "live"
+	LiveStatusOffline = "offline"
+	LiveStatusRound   = "round" // replaying recordings between streams
+	LiveStatusNone    = "none"  // the user has no live room
+)
+
+// chinaTime is the zone of the times the live API reports as text
+var chinaTime = time.FixedZone("CST", 8*60*60)
+
+// LiveRoom is the normalised live room of a user
+type LiveRoom struct {
+	UID       string `json:"uid"`
+	Name      string `json:"name"`
+	RoomID    int64  `json:"roomId"`
+	Status    string `json:"status"`
+	Title     string `json:"title"`
+	Cover     string `json:"cover"`
+	URL       string `json:"url"`
+	Area      string `json:"area"`
+	Online    int64  `json:"online"`
+	Followers int64  `json:"followers"`
+	StartedAt int64  `json:"startedAt"` // unix seconds, 0 when not live
+}
+
+// apiResponse is the envelope of every Bilibili API response
+type apiResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Msg     string          `json:"msg"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// decodeAPI checks the code of an API response and decodes its data
+func decodeAPI(body []byte, v interface{}) error {
+	var resp apiResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("decode response: %v", err)
+	}
+	if resp.Code != 0 {
+		message := resp.Message
+		if message == "" {
+			message = resp.Msg
+		}
+		return &APIError{Code: resp.Code, Message: message}
+	}
+	if err := json.Unmarshal(resp.Data, v); err != nil {
+		return fmt.Errorf("decode response data: %v", err)
+	}
+	return nil
+}
+
+// apiErrorStatus maps the codes Bilibili uses for missing or hidden
+// resources to HTTP statuses
+func apiErrorStatus(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case -404, 62002, 62004, 19002003:
+			return http.StatusNotFound
+		case -403:
+			return http.StatusForbidden
+		case -400:
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusBadGateway
+}
+
+type rawAccountInfo struct {
+	Name     string `json:"name"`
+	LiveRoom *struct {
+		RoomStatus  int     `json:"roomStatus"`
+		LiveStatus  int     `json:"liveStatus"`
+		Title       string  `json:"title"`
+		Cover       string  `json:"cover"`
+		RoomID      flexInt `json:"roomid"`
+		WatchedShow struct {
+			Num flexInt `json:"num"`
+		} `json:"watched_show"`
+	} `json:"live_room"`
+}
+
+type rawRoomInfo struct {
+	LiveStatus int     `json:"live_status"`
+	Title      string  `json:"title"`
+	UserCover  string  `json:"user_cover"`
+	Online     flexInt `json:"online"`
+	Attention  flexInt `json:"attention"`
+	AreaName   string  `json:"area_name"`
+	LiveTime   string  `json:"live_time"`
+}
+
+// liveStatus maps the numeric live_status of a room
+func liveStatus(status int) string {
+	switch status {
+	case 1:
+		return LiveStatusLive
+	case 2:
+		return LiveStatusRound
+	}
+	return LiveStatusOffline
+}
+
+// FetchLive returns the live room of a user with caching
+func (c *Client) FetchLive(uid string) (*LiveRoom, int, error) {
+	entry, err := c.cache.FetchLive(uid, func() (*cache.Entry, error) {
+		return c.loadLive(uid)
+	})
+	if err != nil {
+		return nil, entryStatus(entry, http.StatusBadGateway), err
+	}
+	var room LiveRoom
+	if err := json.Unmarshal(entry.Data, &room); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &room, http.StatusOK, nil
+}
+
+// loadLive finds the room through the WBI-signed account info and fills
+// in the start time and audience from the live API
+func (c *Client) loadLive(uid string) (*cache.Entry, error) {
+	params := url.Values{}
+	params.Set("mid", uid)
+	params.Set("platform", "web")
+	params.Set("web_location", "1550101")
+	body, status, err := c.getSigned("https://api.bilibili.com/x/space/wbi/acc/info", params, "https://space.bilibili.com/"+uid)
+	if err != nil {
+		if entry, ok := c.lastGood("live:"+uid, err); ok {
+			return entry, nil
+		}
+		return &cache.Entry{Status: status}, err
+	}
+	if status != http.StatusOK {
+		return &cache.Entry{Status: status}, fmt.Errorf("Bilibili API status %d", status)
+	}
+	var account rawAccountInfo
+	if err := decodeAPI(body, &account); err != nil {
+		return &cache.Entry{Status: apiErrorStatus(err)}, err
+	}
+
+	room := LiveRoom{UID: uid, Name: account.Name, Status: LiveStatusNone}
+	if r := account.LiveRoom; r != nil && r.RoomStatus == 1 && r.RoomID != 0 {
+		room.RoomID = int64(r.RoomID)
+		room.Status = liveStatus(r.LiveStatus)
+		room.Title = r.Title
+		room.Cover = absoluteURL(r.Cover)
+		room.URL = "https://live.bilibili.com/" + strconv.FormatInt(room.RoomID, 10)
+		room.Online = int64(r.WatchedShow.Num)
+
+		// The room API adds the start time; the account info is enough
+		// when it fails
+		if info, err := c.loadRoomInfo(room.RoomID); err == nil {
+			room.Status = liveStatus(info.LiveStatus)
+			room.Title = info.Title
+			if info.UserCover != "" {
+				room.Cover = absoluteURL(info.UserCover)
+			}
+			room.Area = info.AreaName
+			room.Online = int64(info.Online)
+			room.Followers = int64(info.Attention)
+			if room.Status == LiveStatusLive {
+				if t, err := time.ParseInLocation("2006-01-02 15:04:05", info.LiveTime, chinaTime); err == nil {
+					room.StartedAt = t.Unix()
+				}
+			}
+		} else {
+			fmt.Printf("Bilibili live room %d: %v\n", room.RoomID, err)
+		}
+	}
+
+	data, err := json.Marshal(room)
+	if err != nil {
+		return &cache.Entry{Status: http.StatusInternalServerError}, err
+	}
+	c.cache.SetLastGood("live:"+uid, data)
+	return &cache.Entry{Data: data, ContentType: "application/json", Status: http.StatusOK}, nil
+}
+
+func (c *Client) loadRoomInfo(roomID int64) (*rawRoomInfo, error) {
+	body, status, err := c.get("https://api.live.bilibili.com/room/v1/Room/get_info?room_id="+strconv.FormatInt(roomID, 10), "https://live.bilibili.com/")
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("Bilibili API status %d", status)
+	}
+	var info rawRoomInfo
+	if err := decodeAPI(body, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
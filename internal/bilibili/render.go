@@ -0,0 +1,147 @@
+package bilibili
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSummaryRunes limits titles derived from the post text
+const maxSummaryRunes = 60
+
+// Summary returns a one-line title for a dynamic: its own title, the title
+// of the video or article it carries, or the start of its text
+func (d *Dynamic) Summary() string {
+	switch {
+	case d.Title != "":
+		return d.Title
+	case d.Video != nil && d.Video.Title != "":
+		return d.Video.Title
+	case d.Article != nil && d.Article.Title != "":
+		return d.Article.Title
+	}
+	text := strings.TrimSpace(d.Text)
+	if line, _, ok := strings.Cut(text, "\n"); ok {
+		text = strings.TrimSpace(line)
+	}
+	if utf8.RuneCountInString(text) > maxSummaryRunes {
+		text = string([]rune(text)[:maxSummaryRunes]) + "…"
+	}
+	if text == "" && d.Forward != nil {
+		return "转发: " + d.Forward.Summary()
+	}
+	if text == "" {
+		return d.Author.Name + " 的动态"
+	}
+	return text
+}
+
+// CoverImage returns the most representative image of a dynamic
+func (d *Dynamic) CoverImage() string {
+	switch {
+	case d.Video != nil && d.Video.Cover != "":
+		return absoluteURL(d.Video.Cover)
+	case len(d.Images) > 0:
+		return absoluteURL(d.Images[0].URL)
+	case d.Article != nil && len(d.Article.Covers) > 0:
+		return absoluteURL(d.Article.Covers[0])
+	case d.Forward != nil:
+		return d.Forward.CoverImage()
+	}
+	return ""
+}
+
+// HTML renders a dynamic as an HTML fragment for feed readers. Every image
+// URL is passed through imageURL so it can be served through the proxy,
+// since the Bilibili CDN rejects requests without a Bilibili referer.
+func (d *Dynamic) HTML(imageURL func(string) string) string {
+	var b strings.Builder
+	d.writeHTML(&b, imageURL)
+	return b.String()
+}
+
+func (d *Dynamic) writeHTML(b *strings.Builder, imageURL func(string) string) {
+	img := func(src, alt string) {
+		fmt.Fprintf(b, `<img src="%s" alt="%s">`, html.EscapeString(imageURL(absoluteURL(src))), html.EscapeString(alt))
+	}
+
+	if d.Title != "" {
+		fmt.Fprintf(b, "<h3>%s</h3>", html.EscapeString(d.Title))
+	}
+	if text := d.richTextHTML(); text != "" {
+		fmt.Fprintf(b, "<p>%s</p>", text)
+	}
+	for _, image := range d.Images {
+		b.WriteString("<p>")
+		img(image.URL, "")
+		b.WriteString("</p>")
+	}
+	if v := d.Video; v != nil {
+		fmt.Fprintf(b, `<p><a href="%s">`, html.EscapeString(webURL(v.URL)))
+		if v.Cover != "" {
+			img(v.Cover, v.Title)
+			b.WriteString("<br>")
+		}
+		fmt.Fprintf(b, "%s</a>", html.EscapeString(v.Title))
+		if v.Duration != "" {
+			fmt.Fprintf(b, " (%s)", html.EscapeString(v.Duration))
+		}
+		b.WriteString("</p>")
+		if v.Description != "" {
+			fmt.Fprintf(b, "<p>%s</p>", multiline(v.Description))
+		}
+	}
+	if a := d.Article; a != nil {
+		fmt.Fprintf(b, `<p><a href="%s">%s</a></p>`, html.EscapeString(webURL(a.URL)), html.EscapeString(a.Title))
+		for _, cover := range a.Covers {
+			img(cover, a.Title)
+		}
+		if a.Desc != "" {
+			fmt.Fprintf(b, "<p>%s</p>", multiline(a.Desc))
+		}
+	}
+	if f := d.Forward; f != nil {
+		b.WriteString("<blockquote>")
+		if f.Author.Name != "" {
+			fmt.Fprintf(b, `<p><a href="%s">@%s</a></p>`, html.EscapeString(webURL(f.URL)), html.EscapeString(f.Author.Name))
+		}
+		f.writeHTML(b, imageURL)
+		b.WriteString("</blockquote>")
+	}
+}
+
+// richTextHTML renders the post text with links and mentions. Emoji stay
+// as their [name] text, which readers show at a sensible size.
+func (d *Dynamic) richTextHTML() string {
+	if len(d.RichText) == 0 {
+		return multiline(d.Text)
+	}
+	var b strings.Builder
+	for _, node := range d.RichText {
+		switch {
+		case node.Type == RichTextAt && node.RID != "":
+			fmt.Fprintf(&b, `<a href="https://space.bilibili.com/%s">%s</a>`, html.EscapeString(node.RID), html.EscapeString(node.Text))
+		case node.Type != RichTextEmoji && webURL(node.URL) != "":
+			fmt.Fprintf(&b, `<a href="%s">%s</a>`, html.EscapeString(webURL(node.URL)), html.EscapeString(node.Text))
+		default:
+			b.WriteString(multiline(node.Text))
+		}
+	}
+	return b.String()
+}
+
+// multiline escapes text and keeps its line breaks
+func multiline(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>")
+}
+
+// webURL returns u as an absolute http(s) URL, or "" for anything a reader
+// should not follow, such as javascript: links
+func webURL(u string) string {
+	u = absoluteURL(u)
+	if strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "http://") {
+		return u
+	}
+	return ""
+}
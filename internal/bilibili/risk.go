@@ -0,0 +1,158 @@
+package bilibili
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Risk control codes Bilibili answers with HTTP 200 when it suspects a
+// bot, usually because the WBI keys or the buvid cookies went stale
+const (
+	codeRiskControl    = -352
+	codeRequestBlocked = -412
+)
+
+// Retries of a request blocked by risk control. Delays double after
+// riskBackoff.
+const (
+	riskRetries = 2
+	riskBackoff = 1 * time.Second
+	// Sessions are not reset again within this period, so a burst of
+	// blocked requests triggers one reset
+	sessionResetInterval = 30 * time.Second
+)
+
+// ErrRiskControl is returned when requests stay blocked after retrying
+var ErrRiskControl = errors.New("blocked by Bilibili risk control")
+
+// isRiskControl reports whether a response is a risk control rejection
+func isRiskControl(status int, body []byte) bool {
+	if status == http.StatusPreconditionFailed {
+		return true
+	}
+	code, ok := responseCode(body)
+	return ok && (code == codeRiskControl || code == codeRequestBlocked)
+}
+
+// responseCode returns the code of an API response body
+func responseCode(body []byte) (int, bool) {
+	var check struct {
+		Code *int `json:"code"`
+	}
+	if err := json.Unmarshal(body, &check); err != nil || check.Code == nil {
+		return 0, false
+	}
+	return *check.Code, true
+}
+
+// sessionJar is a cookie jar that can be emptied while requests use it
+type sessionJar struct {
+	mu  sync.RWMutex
+	jar *cookiejar.Jar
+}
+
+func newSessionJar() *sessionJar {
+	jar, _ := cookiejar.New(nil)
+	return &sessionJar{jar: jar}
+}
+
+// SetCookies keeps the anonymous cookies; login cookies belong to the
+// account of the request and are kept by the credential pool
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	shared := make([]*http.Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		if !sessionCookies[c.Name] {
+			shared = append(shared, c)
+		}
+	}
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	j.jar.SetCookies(u, shared)
+}
+
+func (j *sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.jar.Cookies(u)
+}
+
+// Reset drops every cookie
+func (j *sessionJar) Reset() {
+	jar, _ := cookiejar.New(nil)
+	j.mu.Lock()
+	j.jar = jar
+	j.mu.Unlock()
+}
+
+// bootstrapCookies visits the homepage so the jar holds the buvid cookies
+// the API expects from browsers
+func (c *Client) bootstrapCookies() error {
+	req, _ := http.NewRequest("GET", "https://www.bilibili.com/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// resetSession forgets the WBI keys and cookies and bootstraps new ones
+func (c *Client) resetSession() {
+	c.resetMutex.Lock()
+	defer c.resetMutex.Unlock()
+	if time.Since(c.lastReset) < sessionResetInterval {
+		return
+	}
+	c.lastReset = time.Now()
+
+	c.wbiMutex.Lock()
+	c.wbiKeys = WbiKeys{}
+	c.wbiMutex.Unlock()
+	c.jar.Reset()
+
+	if err := c.bootstrapCookies(); err != nil {
+		fmt.Printf("Failed to reinit Bilibili cookies: %v\n", err)
+		return
+	}
+	fmt.Println("Reinitialized Bilibili session after risk control")
+}
+
+// getSigned signs params with the WBI keys and requests endpoint. Risk
+// control rejections reset the session and are retried with backoff;
+// ErrRiskControl is returned with the last body if they persist.
+func (c *Client) getSigned(endpoint string, params url.Values, referer string) ([]byte, int, error) {
+	delay := riskBackoff
+	for attempt := 0; ; attempt++ {
+		// Every attempt is signed anew, with the keys of the new session
+		query := url.Values{}
+		for k, v := range params {
+			query[k] = v
+		}
+		signedQuery, err := c.signWbi(query)
+		if err != nil {
+			return nil, upstreamErrorStatus(err), fmt.Errorf("WBI Sign Error: %w", err)
+		}
+
+		body, status, err := c.get(endpoint+"?"+signedQuery, referer)
+		if err != nil || !isRiskControl(status, body) {
+			return body, status, err
+		}
+		if u, err := url.Parse(endpoint); err == nil {
+			c.upstream.reportFailure(u.Host, ErrRiskControl)
+		}
+		if attempt == riskRetries {
+			return body, http.StatusServiceUnavailable, ErrRiskControl
+		}
+		fmt.Printf("Bilibili risk control on %s, retrying in %s\n", endpoint, delay)
+		c.resetSession()
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,136 @@
+package bilibili
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TimelineSource reports how one account of a timeline was fetched
+type TimelineSource struct {
+	UID   string `json:"uid"`
+	Name  string `json:"name,omitempty"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	Items int    `json:"items"`
+}
+
+// Timeline is the newest page of several feeds merged newest first
+type Timeline struct {
+	Items     []Dynamic        `json:"items"`
+	Sources   []TimelineSource `json:"sources"`
+	FetchedAt int64            `json:"fetchedAt"`
+}
+
+// TimelineUIDs returns the accounts merged by the timeline by default
+func (c *Client) TimelineUIDs() []string {
+	return c.timelineUIDs
+}
+
+// FetchTimeline fetches the newest page of every uid concurrently and
+// merges them. An account that fails is reported in Sources and left out
+// instead of failing the whole timeline. types limits the result to the
+// given normalised dynamic types; nil keeps every type.
+func (c *Client) FetchTimeline(uids []string, types map[string]bool) *Timeline {
+	feeds := make([]*Feed, len(uids))
+	sources := make([]TimelineSource, len(uids))
+
+	var wg sync.WaitGroup
+	for i, uid := range uids {
+		wg.Add(1)
+		go func(i int, uid string) {
+			defer wg.Done()
+			sources[i].UID = uid
+			feed, err := c.fetchFeedPage(uid, "")
+			if err != nil {
+				sources[i].Error = err.Error()
+				return
+			}
+			feeds[i] = feed
+			sources[i].OK = true
+			sources[i].Items = len(feed.Items)
+			for _, d := range feed.Items {
+				if d.Author.Name != "" {
+					sources[i].Name = d.Author.Name
+					break
+				}
+			}
+		}(i, uid)
+	}
+	wg.Wait()
+
+	var items []Dynamic
+	for _, feed := range feeds {
+		if feed != nil {
+			items = append(items, feed.Items...)
+		}
+	}
+	items = mergeTimeline(items)
+
+	filtered := make([]Dynamic, 0, len(items))
+	for _, d := range items {
+		if types == nil || types[d.Type] {
+			filtered = append(filtered, d)
+		}
+	}
+
+	return &Timeline{
+		Items:     filtered,
+		Sources:   sources,
+		FetchedAt: time.Now().Unix(),
+	}
+}
+
+// mergeTimeline sorts dynamics newest first and drops duplicates. Accounts
+// often repost each other, so a repost is dropped when its original is in
+// the timeline, and only the earliest repost of an original that is not
+// is kept.
+func mergeTimeline(items []Dynamic) []Dynamic {
+	originals := make(map[string]bool, len(items))
+	for _, d := range items {
+		if d.Type != DynamicForward {
+			originals[d.ID] = true
+		}
+	}
+
+	// Oldest first so the earliest repost of an original wins
+	sort.SliceStable(items, func(i, j int) bool {
+		return olderDynamic(items[i], items[j])
+	})
+
+	seen := make(map[string]bool, len(items))
+	reposted := make(map[string]bool)
+	merged := make([]Dynamic, 0, len(items))
+	for _, d := range items {
+		if seen[d.ID] {
+			continue
+		}
+		seen[d.ID] = true
+		if d.Type == DynamicForward && d.Forward != nil && d.Forward.ID != "" {
+			if originals[d.Forward.ID] || reposted[d.Forward.ID] {
+				continue
+			}
+			reposted[d.Forward.ID] = true
+		}
+		// Pins are per account and mean nothing in a merged timeline
+		d.Pinned = false
+		merged = append(merged, d)
+	}
+
+	for i, j := 0, len(merged)-1; i < j; i, j = i+1, j-1 {
+		merged[i], merged[j] = merged[j], merged[i]
+	}
+	return merged
+}
+
+// olderDynamic orders dynamics by publish time, then by id. Ids are
+// numeric strings that grow over time.
+func olderDynamic(a, b Dynamic) bool {
+	if a.PublishedAt != b.PublishedAt {
+		return a.PublishedAt < b.PublishedAt
+	}
+	if len(a.ID) != len(b.ID) {
+		return len(a.ID) < len(b.ID)
+	}
+	return a.ID < b.ID
+}
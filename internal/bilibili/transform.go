@@ -0,0 +1,252 @@
+package bilibili
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	// Decoders for the formats served by the Bilibili CDN
+	_ "image/gif"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"snowy_viewer/internal/cache"
+)
+
+// Image transform limits. A decoded source takes four bytes per pixel, so
+// maxSourcePixels and maxConcurrentTransforms bound the memory transforms
+// use at once.
+const (
+	maxTransformSize        = 4096
+	maxSourcePixels         = 20 << 20
+	maxConcurrentTransforms = 2
+	defaultJPEGQuality      = 85
+)
+
+// transformSlots limits how many images are decoded and encoded at once
+var transformSlots = make(chan struct{}, maxConcurrentTransforms)
+
+// Fit modes of an image transform
+const (
+	FitContain = "contain" // scale down to fit inside w×h, keeping the aspect ratio
+	FitCover   = "cover"   // fill w×h, cropping the overflowing part
+	FitFill    = "fill"    // stretch to exactly w×h
+)
+
+// ImageTransform describes a resized or re-encoded variant of an image
+type ImageTransform struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  string // "jpeg", "png" or "" for the source format
+	Quality int
+}
+
+// ParseImageTransform reads the w, h, fit, format and q query parameters
+func ParseImageTransform(query url.Values) (ImageTransform, error) {
+	var t ImageTransform
+	var err error
+	if t.Width, err = parseDimension(query, "w"); err != nil {
+		return t, err
+	}
+	if t.Height, err = parseDimension(query, "h"); err != nil {
+		return t, err
+	}
+
+	t.Fit = strings.ToLower(query.Get("fit"))
+	switch t.Fit {
+	case "":
+		t.Fit = FitContain
+	case FitContain, FitCover, FitFill:
+	default:
+		return t, rejectImage(http.StatusBadRequest, "Invalid fit")
+	}
+	if t.Fit != FitContain && (t.Width == 0 || t.Height == 0) {
+		return t, rejectImage(http.StatusBadRequest, "fit=%s requires both w and h", t.Fit)
+	}
+
+	t.Format = strings.ToLower(query.Get("format"))
+	switch t.Format {
+	case "jpg":
+		t.Format = "jpeg"
+	case "", "jpeg", "png":
+	default:
+		return t, rejectImage(http.StatusBadRequest, "Unsupported format")
+	}
+
+	if q := query.Get("q"); q != "" {
+		t.Quality, err = strconv.Atoi(q)
+		if err != nil || t.Quality < 1 || t.Quality > 100 {
+			return t, rejectImage(http.StatusBadRequest, "Invalid q")
+		}
+	}
+	return t, nil
+}
+
+func parseDimension(query url.Values, key string) (int, error) {
+	raw := query.Get(key)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > maxTransformSize {
+		return 0, rejectImage(http.StatusBadRequest, "Invalid %s", key)
+	}
+	return n, nil
+}
+
+// IsZero reports whether the transform leaves the image untouched
+func (t ImageTransform) IsZero() bool {
+	return t.Width == 0 && t.Height == 0 && t.Format == "" && t.Quality == 0
+}
+
+// cacheKey identifies the variant of imageUrl
+func (t ImageTransform) cacheKey(imageUrl string) string {
+	return fmt.Sprintf("%s#w=%d&h=%d&fit=%s&format=%s&q=%d", imageUrl, t.Width, t.Height, t.Fit, t.Format, t.Quality)
+}
+
+// targetSize returns the output size for a source of w×h
+func (t ImageTransform) targetSize(w, h int) (int, int) {
+	tw, th := t.Width, t.Height
+	switch {
+	case tw == 0 && th == 0:
+		return w, h
+	case t.Fit == FitCover || t.Fit == FitFill:
+		return tw, th
+	case tw == 0:
+		tw = maxTransformSize
+	case th == 0:
+		th = maxTransformSize
+	}
+	// Contain: scale down only, keeping the aspect ratio
+	scale := 1.0
+	if sx := float64(tw) / float64(w); sx < scale {
+		scale = sx
+	}
+	if sy := float64(th) / float64(h); sy < scale {
+		scale = sy
+	}
+	return max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))
+}
+
+// Apply decodes an image, resizes it and encodes it in the target format
+func (t ImageTransform) Apply(data []byte) ([]byte, string, error) {
+	cfg, sourceFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", rejectImage(http.StatusUnsupportedMediaType, "Unsupported image: %v", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxSourcePixels {
+		return nil, "", rejectImage(http.StatusUnprocessableEntity, "Image dimensions too large")
+	}
+
+	transformSlots <- struct{}{}
+	defer func() { <-transformSlots }()
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", rejectImage(http.StatusUnsupportedMediaType, "Unsupported image: %v", err)
+	}
+
+	bounds := src.Bounds()
+	w, h := t.targetSize(bounds.Dx(), bounds.Dy())
+	dst := src
+	if w != bounds.Dx() || h != bounds.Dy() {
+		srcRect := bounds
+		if t.Fit == FitCover {
+			srcRect = coverCrop(bounds, w, h)
+		}
+		resized := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(resized, resized.Bounds(), src, srcRect, draw.Src, nil)
+		dst = resized
+	}
+
+	format := t.Format
+	if format == "" {
+		// JPEG stays JPEG; formats that may carry transparency become PNG
+		format = "png"
+		if sourceFormat == "jpeg" {
+			format = "jpeg"
+		}
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		quality := t.Quality
+		if quality == 0 {
+			quality = defaultJPEGQuality
+		}
+		err = jpeg.Encode(&buf, flatten(dst), &jpeg.Options{Quality: quality})
+	default:
+		err = (&png.Encoder{CompressionLevel: png.BestSpeed}).Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/" + format, nil
+}
+
+// coverCrop returns the centered part of bounds with the aspect ratio of
+// w×h
+func coverCrop(bounds image.Rectangle, w, h int) image.Rectangle {
+	sw, sh := bounds.Dx(), bounds.Dy()
+	if sw*h > sh*w {
+		cw := sh * w / h
+		x := bounds.Min.X + (sw-cw)/2
+		return image.Rect(x, bounds.Min.Y, x+cw, bounds.Max.Y)
+	}
+	ch := sw * h / w
+	y := bounds.Min.Y + (sh-ch)/2
+	return image.Rect(bounds.Min.X, y, bounds.Max.X, y+ch)
+}
+
+// flatten draws an image on white, since JPEG has no transparency
+func flatten(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Over)
+	return out
+}
+
+// FetchImageVariant fetches an image and applies a transform. Variants are
+// cached separately from the original, which is cached as well.
+func (c *Client) FetchImageVariant(imageUrl string, t ImageTransform) ([]byte, string, int, error) {
+	if t.IsZero() {
+		return c.FetchImage(imageUrl)
+	}
+	u, err := c.imagePolicy.ValidateImageURL(imageUrl)
+	if err != nil {
+		return nil, "", imageErrorStatus(err, http.StatusBadRequest), err
+	}
+	imageUrl = u.String()
+
+	entry, err := c.cache.FetchImage(t.cacheKey(imageUrl), func() (*cache.Entry, error) {
+		data, _, status, err := c.FetchImage(imageUrl)
+		if err != nil {
+			return &cache.Entry{Status: status}, err
+		}
+		if status != http.StatusOK {
+			return &cache.Entry{Status: status, NoStore: true}, nil
+		}
+		variant, contentType, err := t.Apply(data)
+		if err != nil {
+			return &cache.Entry{Status: imageErrorStatus(err, http.StatusInternalServerError)}, err
+		}
+		return &cache.Entry{Data: variant, ContentType: contentType, Status: http.StatusOK}, nil
+	})
+	if err != nil {
+		return nil, "", entryStatus(entry, http.StatusBadGateway), err
+	}
+	return entry.Data, entry.ContentType, entry.Status, nil
+}
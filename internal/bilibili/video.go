@@ -0,0 +1,149 @@
+package bilibili
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"snowy_viewer/internal/cache"
+)
+
+// bvidPattern matches BV ids, e.g. BV1xx411c7mD
+var bvidPattern = regexp.MustCompile(`^BV[0-9A-Za-z]{10}$`)
+
+// ValidBVID reports whether bvid is a well-formed BV id
+func ValidBVID(bvid string) bool {
+	return bvidPattern.MatchString(bvid)
+}
+
+// Video states
+const (
+	VideoStatusPublic      = "public"
+	VideoStatusUnavailable = "unavailable" // under review, locked or removed
+)
+
+// VideoDetail is the normalised card of a video
+type VideoDetail struct {
+	BVID        string     `json:"bvid"`
+	AID         string     `json:"aid"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Cover       string     `json:"cover"`
+	Status      string     `json:"status"`
+	Duration    int64      `json:"duration"` // seconds
+	Pages       int        `json:"pages"`
+	Author      Author     `json:"author"`
+	Stats       VideoStats `json:"stats"`
+	PublishedAt int64      `json:"publishedAt"` // unix seconds
+	URL         string     `json:"url"`
+}
+
+type VideoStats struct {
+	View     int64 `json:"view"`
+	Danmaku  int64 `json:"danmaku"`
+	Reply    int64 `json:"reply"`
+	Favorite int64 `json:"favorite"`
+	Coin     int64 `json:"coin"`
+	Share    int64 `json:"share"`
+	Like     int64 `json:"like"`
+}
+
+type rawVideoView struct {
+	BVID     string     `json:"bvid"`
+	AID      flexString `json:"aid"`
+	Title    string     `json:"title"`
+	Desc     string     `json:"desc"`
+	Pic      string     `json:"pic"`
+	State    int        `json:"state"`
+	Duration flexInt    `json:"duration"`
+	Videos   int        `json:"videos"`
+	Pubdate  flexInt    `json:"pubdate"`
+	Owner    struct {
+		Mid  flexInt `json:"mid"`
+		Name string  `json:"name"`
+		Face string  `json:"face"`
+	} `json:"owner"`
+	Stat struct {
+		View     flexInt `json:"view"`
+		Danmaku  flexInt `json:"danmaku"`
+		Reply    flexInt `json:"reply"`
+		Favorite flexInt `json:"favorite"`
+		Coin     flexInt `json:"coin"`
+		Share    flexInt `json:"share"`
+		Like     flexInt `json:"like"`
+	} `json:"stat"`
+}
+
+// FetchVideo returns the card of a video with caching
+func (c *Client) FetchVideo(bvid string) (*VideoDetail, int, error) {
+	entry, err := c.cache.FetchVideo(bvid, func() (*cache.Entry, error) {
+		return c.loadVideo(bvid)
+	})
+	if err != nil {
+		return nil, entryStatus(entry, http.StatusBadGateway), err
+	}
+	var video VideoDetail
+	if err := json.Unmarshal(entry.Data, &video); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &video, http.StatusOK, nil
+}
+
+func (c *Client) loadVideo(bvid string) (*cache.Entry, error) {
+	params := url.Values{}
+	params.Set("bvid", bvid)
+	body, status, err := c.getSigned("https://api.bilibili.com/x/web-interface/wbi/view", params, "https://www.bilibili.com/video/"+bvid)
+	if err != nil {
+		if entry, ok := c.lastGood("video:"+bvid, err); ok {
+			return entry, nil
+		}
+		return &cache.Entry{Status: status}, err
+	}
+	if status != http.StatusOK {
+		return &cache.Entry{Status: status}, fmt.Errorf("Bilibili API status %d", status)
+	}
+	var raw rawVideoView
+	if err := decodeAPI(body, &raw); err != nil {
+		return &cache.Entry{Status: apiErrorStatus(err)}, err
+	}
+
+	video := VideoDetail{
+		BVID:        raw.BVID,
+		AID:         string(raw.AID),
+		Title:       raw.Title,
+		Description: raw.Desc,
+		Cover:       absoluteURL(raw.Pic),
+		Status:      VideoStatusPublic,
+		Duration:    int64(raw.Duration),
+		Pages:       raw.Videos,
+		Author: Author{
+			MID:  int64(raw.Owner.Mid),
+			Name: raw.Owner.Name,
+			Face: absoluteURL(raw.Owner.Face),
+		},
+		Stats: VideoStats{
+			View:     int64(raw.Stat.View),
+			Danmaku:  int64(raw.Stat.Danmaku),
+			Reply:    int64(raw.Stat.Reply),
+			Favorite: int64(raw.Stat.Favorite),
+			Coin:     int64(raw.Stat.Coin),
+			Share:    int64(raw.Stat.Share),
+			Like:     int64(raw.Stat.Like),
+		},
+		PublishedAt: int64(raw.Pubdate),
+		URL:         "https://www.bilibili.com/video/" + raw.BVID,
+	}
+	// Negative states are reviews, locks and removals
+	if raw.State < 0 {
+		video.Status = VideoStatusUnavailable
+	}
+
+	data, err := json.Marshal(video)
+	if err != nil {
+		return &cache.Entry{Status: http.StatusInternalServerError}, err
+	}
+	c.cache.SetLastGood("video:"+bvid, data)
+	return &cache.Entry{Data: data, ContentType: "application/json", Status: http.StatusOK}, nil
+}
@@ -0,0 +1,80 @@
+package cache
+
+import "time"
+
+// Backend is a key/value store with per-entry expiry. Implementations must
+// be safe for concurrent use.
+type Backend interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+	Close() error
+}
+
+// ttlReader is implemented by backends that can report the remaining
+// lifetime of an entry, which lets a Tiered cache promote it
+type ttlReader interface {
+	TTL(key string) (time.Duration, bool)
+}
+
+// Tiered looks keys up in each backend in order, e.g. memory in front of
+// Redis. Hits in a slower tier are copied into the faster tiers before it,
+// writes and deletes go to every tier.
+type Tiered struct {
+	tiers []Backend
+}
+
+// NewTiered composes backends, fastest first
+func NewTiered(tiers ...Backend) *Tiered {
+	return &Tiered{tiers: tiers}
+}
+
+func (t *Tiered) Get(key string) ([]byte, bool) {
+	for i, tier := range t.tiers {
+		value, ok := tier.Get(key)
+		if !ok {
+			continue
+		}
+		if i > 0 {
+			if r, ok := tier.(ttlReader); ok {
+				if ttl, ok := r.TTL(key); ok {
+					for _, faster := range t.tiers[:i] {
+						faster.Set(key, value, ttl)
+					}
+				}
+			}
+		}
+		return value, true
+	}
+	return nil, false
+}
+
+func (t *Tiered) Set(key string, value []byte, ttl time.Duration) error {
+	var firstErr error
+	for _, tier := range t.tiers {
+		if err := tier.Set(key, value, ttl); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (t *Tiered) Delete(key string) error {
+	var firstErr error
+	for _, tier := range t.tiers {
+		if err := tier.Delete(key); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (t *Tiered) Close() error {
+	var firstErr error
+	for _, tier := range t.tiers {
+		if err := tier.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
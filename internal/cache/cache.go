@@ -1,153 +1,210 @@
 package cache
 
 import (
-	"context"
+	"errors"
 	"fmt"
-	"sync"
 	"time"
-
-	"github.com/redis/go-redis/v9"
 )
 
-type Cache struct {
-	redis       *redis.Client
-	memoryCache sync.Map
-	useRedis    bool
+// Options configures the cache tiers
+type Options struct {
+	// RedisURL enables a Redis tier behind the memory tier
+	RedisURL string
+	// MemoryMaxBytes bounds the memory tier; <= 0 means unbounded
+	MemoryMaxBytes int64
+	// Dir enables a disk tier behind the other tiers
+	Dir string
+	// JanitorInterval is how often expired entries are purged
+	JanitorInterval time.Duration
+	// ImageDir enables the persistent image cache, which keeps images out
+	// of the other tiers
+	ImageDir string
+	// ImageMaxBytes is the quota of the image cache; <= 0 means unbounded
+	ImageMaxBytes int64
+	// HealthCheckInterval is how often Redis is pinged to detect outages
+	// and recovery
+	HealthCheckInterval time.Duration
 }
 
-type MemoryCacheItem struct {
-	Data      []byte
-	ExpiresAt time.Time
+type Cache struct {
+	backend Backend
+	memory  *Memory
+	redis   *Failover
+	images  *ImageStore
+	flight  flightGroup
 }
 
-var ctx = context.Background()
-
-// New creates a new cache instance with Redis support
-// Falls back to memory cache if Redis is unavailable
-func New(redisURL string) *Cache {
+// New creates a cache with a size-bounded memory tier in front of Redis
+// and the disk tier when they are configured. While Redis is unreachable
+// the cache runs on the other tiers and switches back once it recovers.
+func New(opts Options) *Cache {
+	if opts.JanitorInterval <= 0 {
+		opts.JanitorInterval = time.Minute
+	}
+	if opts.HealthCheckInterval <= 0 {
+		opts.HealthCheckInterval = 10 * time.Second
+	}
 	c := &Cache{
-		useRedis: false,
+		memory: NewMemory(opts.MemoryMaxBytes, opts.JanitorInterval),
 	}
+	tiers := []Backend{c.memory}
 
-	if redisURL != "" {
-		var opts *redis.Options
-		var err error
+	if opts.RedisURL != "" {
+		c.redis = NewFailover(opts.RedisURL, opts.HealthCheckInterval)
+		tiers = append(tiers, c.redis)
+	}
 
-		// Try to parse as connection URL first
-		if opts, err = redis.ParseURL(redisURL); err != nil {
-			// Fallback: treat as direct address
-			opts = &redis.Options{
-				Addr: redisURL,
-			}
+	if opts.Dir != "" {
+		d, err := NewDisk(opts.Dir, opts.JanitorInterval)
+		if err != nil {
+			fmt.Printf("Disk cache unavailable (%s): %v\n", opts.Dir, err)
+		} else {
+			tiers = append(tiers, d)
 		}
+	}
 
-		client := redis.NewClient(opts)
-
-		// Test connection with timeout
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		_, err = client.Ping(ctx).Result()
+	if opts.ImageDir != "" {
+		images, err := NewImageStore(opts.ImageDir, opts.ImageMaxBytes, time.Minute)
 		if err != nil {
-			fmt.Printf("Redis connection failed (%s), using memory cache: %v\n", redisURL, err)
-			return c
+			fmt.Printf("Image cache unavailable (%s): %v\n", opts.ImageDir, err)
+		} else {
+			c.images = images
 		}
-
-		c.redis = client
-		c.useRedis = true
-		// Mask password in log if present
-		fmt.Printf("Redis connected successfully: %s\n", opts.Addr)
 	}
 
+	c.backend = NewTiered(tiers...)
 	return c
 }
 
 // Get retrieves a value from cache
 func (c *Cache) Get(key string) ([]byte, bool) {
-	if c.useRedis {
-		val, err := c.redis.Get(ctx, key).Bytes()
-		if err == nil {
-			return val, true
-		}
-		return nil, false
-	}
-
-	// Memory cache fallback
-	if item, ok := c.memoryCache.Load(key); ok {
-		cached := item.(MemoryCacheItem)
-		if time.Now().Before(cached.ExpiresAt) {
-			return cached.Data, true
-		}
-		c.memoryCache.Delete(key)
-	}
-	return nil, false
+	return c.backend.Get(key)
 }
 
 // Set stores a value in cache with TTL
 func (c *Cache) Set(key string, value []byte, ttl time.Duration) error {
-	if c.useRedis {
-		return c.redis.Set(ctx, key, value, ttl).Err()
-	}
-
-	// Memory cache fallback
-	c.memoryCache.Store(key, MemoryCacheItem{
-		Data:      value,
-		ExpiresAt: time.Now().Add(ttl),
-	})
-	return nil
+	return c.backend.Set(key, value, ttl)
 }
 
 // Delete removes a key from cache
 func (c *Cache) Delete(key string) error {
-	if c.useRedis {
-		return c.redis.Del(ctx, key).Err()
-	}
-	c.memoryCache.Delete(key)
-	return nil
+	return c.backend.Delete(key)
 }
 
 // Bilibili Dynamic Cache helpers
 const (
-	DynamicCacheTTL = 10 * time.Minute
-	ImageCacheTTL   = 1 * time.Hour
+	DynamicCacheTTL     = 10 * time.Minute
+	DynamicPageCacheTTL = 1 * time.Hour
+	ImageCacheTTL       = 1 * time.Hour
+	LiveCacheTTL        = 1 * time.Minute
+	VideoCacheTTL       = 10 * time.Minute
+
+	// Expired entries are still served for this long while refreshing
+	DynamicStaleTTL = 1 * time.Hour
+	ImageStaleTTL   = 24 * time.Hour
+	LiveStaleTTL    = 10 * time.Minute
+	VideoStaleTTL   = 1 * time.Hour
 )
 
-func (c *Cache) GetDynamic(uid string) ([]byte, bool) {
-	return c.Get("dynamic:" + uid)
+// LastGoodTTL keeps the last successful response of a feed to fall back on
+// while Bilibili rejects our requests
+const LastGoodTTL = 7 * 24 * time.Hour
+
+// SetLastGood records the last successful response for key
+func (c *Cache) SetLastGood(key string, data []byte) {
+	if err := c.Set("lastgood:"+key, data, LastGoodTTL); err != nil && !errors.Is(err, ErrRedisUnavailable) {
+		fmt.Printf("Warning: failed to store last good response: %v\n", err)
+	}
+}
+
+// LastGood returns the last successful response for key
+func (c *Cache) LastGood(key string) ([]byte, bool) {
+	return c.Get("lastgood:" + key)
 }
 
-func (c *Cache) SetDynamic(uid string, data []byte) error {
-	return c.Set("dynamic:"+uid, data, DynamicCacheTTL)
+// FetchLive caches the live room of a user. Rooms go on and off air at any
+// time, so entries are short-lived.
+func (c *Cache) FetchLive(uid string, load Loader) (*Entry, error) {
+	return c.Fetch("live:"+uid, LiveCacheTTL, LiveStaleTTL, load)
 }
 
-func (c *Cache) GetImage(url string) ([]byte, string, bool) {
-	data, ok := c.Get("img:" + url)
-	if !ok {
-		return nil, "", false
-	}
-	contentType, ok := c.Get("img_ct:" + url)
-	if !ok {
-		return nil, "", false
+// FetchVideo caches the details of a video
+func (c *Cache) FetchVideo(bvid string, load Loader) (*Entry, error) {
+	return c.Fetch("video:"+bvid, VideoCacheTTL, VideoStaleTTL, load)
+}
+
+// FetchDynamicPage caches a page of a feed. The first page changes with
+// every post while older pages only change when posts are deleted.
+func (c *Cache) FetchDynamicPage(uid, offset string, load Loader) (*Entry, error) {
+	if offset == "" {
+		return c.Fetch("dynamic:"+uid, DynamicCacheTTL, DynamicStaleTTL, load)
 	}
-	return data, string(contentType), true
+	return c.Fetch("dynamic:"+uid+":"+offset, DynamicPageCacheTTL, DynamicStaleTTL, load)
 }
 
-func (c *Cache) SetImage(url string, data []byte, contentType string) error {
-	if err := c.Set("img:"+url, data, ImageCacheTTL); err != nil {
-		return err
+// FetchImage returns an image from the image cache when it is enabled,
+// since CDN images never change, and from the TTL-based tiers otherwise
+func (c *Cache) FetchImage(url string, load Loader) (*Entry, error) {
+	if c.images == nil {
+		return c.Fetch("img:"+url, ImageCacheTTL, ImageStaleTTL, load)
+	}
+	if entry, ok := c.images.Get(url); ok {
+		return entry, nil
 	}
-	return c.Set("img_ct:"+url, []byte(contentType), ImageCacheTTL)
+	return c.flight.do("img:"+url, func() (*Entry, error) {
+		entry, err := load()
+		if err == nil && entry != nil && !entry.NoStore {
+			if err := c.images.Put(url, entry); err != nil {
+				fmt.Printf("Warning: failed to cache image: %v\n", err)
+			}
+		}
+		return entry, err
+	})
 }
 
-// IsRedisEnabled returns whether Redis is being used
+// IsRedisEnabled returns whether Redis is currently being used
 func (c *Cache) IsRedisEnabled() bool {
-	return c.useRedis
+	return c.redis != nil && c.redis.Healthy()
+}
+
+// Status describes the active cache tiers
+type Status struct {
+	Mode          string       `json:"mode"`
+	Redis         *RedisStatus `json:"redis,omitempty"`
+	MemoryEntries int          `json:"memoryEntries"`
+	MemoryBytes   int64        `json:"memoryBytes"`
+	ImageEntries  int          `json:"imageEntries"`
+	ImageBytes    int64        `json:"imageBytes"`
+}
+
+// Status returns the current cache mode, "redis" or "memory"
+func (c *Cache) Status() Status {
+	status := Status{Mode: "memory"}
+	if c.redis != nil {
+		redisStatus := c.redis.Status()
+		status.Redis = &redisStatus
+		if redisStatus.Healthy {
+			status.Mode = "redis"
+		}
+	}
+	status.MemoryEntries, status.MemoryBytes = c.memory.Size()
+	if c.images != nil {
+		status.ImageEntries, status.ImageBytes = c.images.Usage()
+	}
+	return status
+}
+
+// MemoryUsage returns the number of entries in the memory tier and their
+// size in bytes
+func (c *Cache) MemoryUsage() (int, int64) {
+	return c.memory.Size()
 }
 
-// Close closes Redis connection if enabled
+// Close stops the janitors, writes the image cache index and closes the
+// Redis connection if enabled
 func (c *Cache) Close() error {
-	if c.useRedis && c.redis != nil {
-		return c.redis.Close()
+	if c.images != nil {
+		c.images.Close()
 	}
-	return nil
+	return c.backend.Close()
 }
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"snowy_viewer/internal/fsutil"
+)
+
+// diskHeaderSize is the expiry timestamp stored in front of every value
+const diskHeaderSize = 8
+
+// Disk is a backend storing one file per key in a directory, so entries
+// survive restarts without Redis. Expired files are removed on access and
+// by a background janitor.
+type Disk struct {
+	dir      string
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDisk creates a disk backend in dir and starts its janitor
+func NewDisk(dir string, janitorInterval time.Duration) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	d := &Disk{dir: dir, stop: make(chan struct{})}
+	if janitorInterval > 0 {
+		go d.janitor(janitorInterval)
+	}
+	return d, nil
+}
+
+// path maps a key to a file name that is safe on every file system
+func (d *Disk) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
+
+func (d *Disk) read(path string) ([]byte, time.Time, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(content) < diskHeaderSize {
+		return nil, time.Time{}, errors.New("truncated cache file")
+	}
+	expiresAt := time.Unix(0, int64(binary.BigEndian.Uint64(content)))
+	return content[diskHeaderSize:], expiresAt, nil
+}
+
+// readExpiry reads only the header of a cache file
+func (d *Disk) readExpiry(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	var header [diskHeaderSize]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(header[:]))), nil
+}
+
+func (d *Disk) Get(key string) ([]byte, bool) {
+	path := d.path(key)
+	data, expiresAt, err := d.read(path)
+	if err != nil {
+		return nil, false
+	}
+	if time.Now().After(expiresAt) {
+		os.Remove(path)
+		return nil, false
+	}
+	return data, true
+}
+
+func (d *Disk) Set(key string, value []byte, ttl time.Duration) error {
+	content := make([]byte, diskHeaderSize+len(value))
+	binary.BigEndian.PutUint64(content, uint64(time.Now().Add(ttl).UnixNano()))
+	copy(content[diskHeaderSize:], value)
+	return fsutil.WriteFileAtomic(d.path(key), content, 0o644)
+}
+
+func (d *Disk) Delete(key string) error {
+	if err := os.Remove(d.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// TTL returns the remaining lifetime of an entry
+func (d *Disk) TTL(key string) (time.Duration, bool) {
+	expiresAt, err := d.readExpiry(d.path(key))
+	if err != nil {
+		return 0, false
+	}
+	ttl := time.Until(expiresAt)
+	return ttl, ttl > 0
+}
+
+// Close stops the janitor
+func (d *Disk) Close() error {
+	d.stopOnce.Do(func() { close(d.stop) })
+	return nil
+}
+
+func (d *Disk) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.deleteExpired()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+func (d *Disk) deleteExpired() {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(d.dir, entry.Name())
+		if expiresAt, err := d.readExpiry(path); err != nil || now.After(expiresAt) {
+			os.Remove(path)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrRedisUnavailable is returned by writes while Redis is down. A Tiered
+// cache still applies them to its memory tier.
+var ErrRedisUnavailable = errors.New("redis unavailable")
+
+// maxStaleKeys bounds the keys remembered during an outage. Beyond it the
+// whole database is flushed when Redis comes back.
+const maxStaleKeys = 10000
+
+// Failover wraps a Redis tier that may come and go. While Redis is
+// unreachable reads are cheap misses and writes fail fast, so a Tiered
+// cache degrades to its memory tier. Keys written or deleted meanwhile
+// would keep their old value in Redis, so they are removed from it before
+// a health check re-attaches Redis.
+type Failover struct {
+	url      string
+	interval time.Duration
+
+	mu         sync.RWMutex
+	redis      *Redis // nil until the first successful connection
+	healthy    bool
+	lastError  string
+	lastChange time.Time
+	stale      map[string]struct{} // keys changed while unhealthy
+	flushAll   bool                // too many stale keys to track
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFailover connects to redisURL and starts the health check. A failed
+// initial connection is retried by the health check.
+func NewFailover(redisURL string, interval time.Duration) *Failover {
+	f := &Failover{
+		url:        redisURL,
+		interval:   interval,
+		lastChange: time.Now(),
+		stale:      make(map[string]struct{}),
+		stop:       make(chan struct{}),
+	}
+	f.check()
+	if !f.Healthy() {
+		fmt.Printf("Redis connection failed (%s), using memory cache until it recovers: %s\n", redisURL, f.Status().LastError)
+	}
+	go f.healthCheck()
+	return f
+}
+
+// current returns the Redis client if it is healthy
+func (f *Failover) current() *Redis {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.healthy {
+		return nil
+	}
+	return f.redis
+}
+
+// setHealthy records a health change and logs transitions
+func (f *Failover) setHealthy(healthy bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.setHealthyLocked(healthy, err)
+}
+
+// setHealthyLocked is setHealthy with f.mu held
+func (f *Failover) setHealthyLocked(healthy bool, err error) {
+	if err != nil {
+		f.lastError = err.Error()
+	}
+	if f.healthy == healthy {
+		return
+	}
+	f.healthy = healthy
+	f.lastChange = time.Now()
+	if healthy {
+		addr := f.url
+		if f.redis != nil {
+			addr = f.redis.Addr()
+		}
+		fmt.Printf("Redis connected successfully: %s\n", addr)
+	} else {
+		fmt.Printf("Redis unavailable, using memory cache: %v\n", err)
+	}
+}
+
+// check pings Redis, connecting first if there never was a connection
+func (f *Failover) check() {
+	f.mu.RLock()
+	r := f.redis
+	f.mu.RUnlock()
+
+	if r == nil {
+		connected, err := NewRedis(f.url)
+		if err != nil {
+			f.setHealthy(false, err)
+			return
+		}
+		f.mu.Lock()
+		f.redis = connected
+		f.mu.Unlock()
+		r = connected
+	} else if err := r.Ping(); err != nil {
+		f.setHealthy(false, err)
+		return
+	}
+	if err := f.reattach(r); err != nil {
+		f.setHealthy(false, err)
+	}
+}
+
+// reattach removes the keys changed during the outage from Redis and marks
+// it healthy once none are left. Keys changed while it runs are picked up
+// by the next round.
+func (f *Failover) reattach(r *Redis) error {
+	for {
+		f.mu.Lock()
+		if f.healthy {
+			f.mu.Unlock()
+			return nil
+		}
+		keys := make([]string, 0, len(f.stale))
+		for key := range f.stale {
+			keys = append(keys, key)
+		}
+		flushAll := f.flushAll
+		if len(keys) == 0 && !flushAll {
+			f.setHealthyLocked(true, nil)
+			f.mu.Unlock()
+			return nil
+		}
+		f.stale = make(map[string]struct{})
+		f.flushAll = false
+		f.mu.Unlock()
+
+		var err error
+		if flushAll {
+			err = r.flush()
+		} else {
+			err = r.deleteKeys(keys)
+		}
+		if err != nil {
+			f.mu.Lock()
+			f.flushAll = f.flushAll || flushAll
+			for _, key := range keys {
+				f.markStaleLocked(key)
+			}
+			f.mu.Unlock()
+			return err
+		}
+		if flushAll {
+			fmt.Println("Redis flushed, too many keys changed while it was unavailable")
+		} else {
+			fmt.Printf("Redis: invalidated %d keys changed while it was unavailable\n", len(keys))
+		}
+	}
+}
+
+func (f *Failover) healthCheck() {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f.check()
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// fail marks Redis down after a failed command
+func (f *Failover) fail(err error) {
+	f.setHealthy(false, err)
+}
+
+// failKey marks Redis down after a failed write of key, and key stale
+func (f *Failover) failKey(key string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.setHealthyLocked(false, err)
+	f.markStaleLocked(key)
+}
+
+// markStale records a write of key that Redis missed. It returns the
+// client instead if Redis became healthy in the meantime.
+func (f *Failover) markStale(key string) *Redis {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.healthy {
+		return f.redis
+	}
+	f.markStaleLocked(key)
+	return nil
+}
+
+// markStaleLocked is markStale with f.mu held and Redis unhealthy
+func (f *Failover) markStaleLocked(key string) {
+	if f.flushAll {
+		return
+	}
+	if len(f.stale) >= maxStaleKeys {
+		f.flushAll = true
+		f.stale = make(map[string]struct{})
+		return
+	}
+	f.stale[key] = struct{}{}
+}
+
+func (f *Failover) Get(key string) ([]byte, bool) {
+	r := f.current()
+	if r == nil {
+		return nil, false
+	}
+	val, err := r.get(key)
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			f.fail(err)
+		}
+		return nil, false
+	}
+	return val, true
+}
+
+func (f *Failover) Set(key string, value []byte, ttl time.Duration) error {
+	r := f.current()
+	if r == nil {
+		if r = f.markStale(key); r == nil {
+			return ErrRedisUnavailable
+		}
+	}
+	if err := r.Set(key, value, ttl); err != nil {
+		f.failKey(key, err)
+		return err
+	}
+	return nil
+}
+
+func (f *Failover) Delete(key string) error {
+	r := f.current()
+	if r == nil {
+		if r = f.markStale(key); r == nil {
+			return ErrRedisUnavailable
+		}
+	}
+	if err := r.Delete(key); err != nil {
+		f.failKey(key, err)
+		return err
+	}
+	return nil
+}
+
+// TTL returns the remaining lifetime of a key
+func (f *Failover) TTL(key string) (time.Duration, bool) {
+	r := f.current()
+	if r == nil {
+		return 0, false
+	}
+	return r.TTL(key)
+}
+
+// Healthy reports whether Redis is currently in use
+func (f *Failover) Healthy() bool {
+	return f.current() != nil
+}
+
+// RedisStatus describes the Redis tier
+type RedisStatus struct {
+	Healthy    bool      `json:"healthy"`
+	Addr       string    `json:"addr,omitempty"`
+	LastError  string    `json:"lastError,omitempty"`
+	LastChange time.Time `json:"lastChange"`
+}
+
+// Status returns the health of the Redis tier
+func (f *Failover) Status() RedisStatus {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	status := RedisStatus{
+		Healthy:    f.healthy,
+		LastError:  f.lastError,
+		LastChange: f.lastChange,
+	}
+	if f.redis != nil {
+		status.Addr = f.redis.Addr()
+	}
+	return status
+}
+
+// Close stops the health check and closes the connection
+func (f *Failover) Close() error {
+	f.stopOnce.Do(func() { close(f.stop) })
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.redis != nil {
+		return f.redis.Close()
+	}
+	return nil
+}
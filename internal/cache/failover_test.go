@@ -0,0 +1,232 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis speaks enough RESP for the Redis backend. It can be stopped and
+// restarted on the same address to simulate an outage.
+type fakeRedis struct {
+	addr string
+
+	mu    sync.Mutex
+	data  map[string]string
+	ln    net.Listener
+	conns map[net.Conn]bool
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	r := &fakeRedis{data: make(map[string]string)}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.addr = ln.Addr().String()
+	r.serve(ln)
+	t.Cleanup(r.stop)
+	return r
+}
+
+func (r *fakeRedis) serve(ln net.Listener) {
+	r.mu.Lock()
+	r.ln = ln
+	r.conns = make(map[net.Conn]bool)
+	r.mu.Unlock()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r.mu.Lock()
+			r.conns[conn] = true
+			r.mu.Unlock()
+			go r.handle(conn)
+		}
+	}()
+}
+
+func (r *fakeRedis) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ln != nil {
+		r.ln.Close()
+		r.ln = nil
+	}
+	for conn := range r.conns {
+		conn.Close()
+	}
+}
+
+func (r *fakeRedis) restart(t *testing.T) {
+	ln, err := net.Listen("tcp", r.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.serve(ln)
+}
+
+func (r *fakeRedis) value(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.data[key]
+	return v, ok
+}
+
+func (r *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			reply = "+PONG\r\n"
+		case "GET":
+			if v, ok := r.data[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SET":
+			r.data[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case "DEL":
+			n := 0
+			for _, key := range args[1:] {
+				if _, ok := r.data[key]; ok {
+					delete(r.data, key)
+					n++
+				}
+			}
+			reply = fmt.Sprintf(":%d\r\n", n)
+		case "FLUSHDB":
+			r.data = make(map[string]string)
+			reply = "+OK\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		r.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads one RESP array of bulk strings
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, errors.New("not an array")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || n < 1 {
+		return nil, errors.New("bad array length")
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func newTestFailover(t *testing.T, addr string) *Failover {
+	t.Helper()
+	// The health check is driven by the test
+	f := NewFailover(addr, time.Hour)
+	t.Cleanup(func() { f.Close() })
+	if !f.Healthy() {
+		t.Fatalf("not connected: %s", f.Status().LastError)
+	}
+	return f
+}
+
+func TestFailoverInvalidatesKeysChangedDuringOutage(t *testing.T) {
+	srv := newFakeRedis(t)
+	f := newTestFailover(t, srv.addr)
+	for _, key := range []string{"written", "deleted", "untouched"} {
+		if err := f.Set(key, []byte("old"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv.stop()
+	if _, ok := f.Get("untouched"); ok {
+		t.Fatal("read from a stopped server succeeded")
+	}
+	if f.Healthy() {
+		t.Fatal("still healthy after a failed read")
+	}
+	if err := f.Set("written", []byte("new"), time.Minute); !errors.Is(err, ErrRedisUnavailable) {
+		t.Errorf("Set during outage = %v, want ErrRedisUnavailable", err)
+	}
+	if err := f.Delete("deleted"); !errors.Is(err, ErrRedisUnavailable) {
+		t.Errorf("Delete during outage = %v, want ErrRedisUnavailable", err)
+	}
+
+	srv.restart(t)
+	f.check()
+	if !f.Healthy() {
+		t.Fatalf("not re-attached: %s", f.Status().LastError)
+	}
+	for _, key := range []string{"written", "deleted"} {
+		if v, ok := srv.value(key); ok {
+			t.Errorf("%s = %q in Redis after re-attach, want removed", key, v)
+		}
+	}
+	if v, _ := srv.value("untouched"); v != "old" {
+		t.Errorf("untouched = %q, want old", v)
+	}
+}
+
+func TestFailoverFlushesAfterTooManyChanges(t *testing.T) {
+	srv := newFakeRedis(t)
+	f := newTestFailover(t, srv.addr)
+	if err := f.Set("untouched", []byte("old"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.stop()
+	f.Get("untouched")
+	for i := 0; i <= maxStaleKeys; i++ {
+		f.Set("key"+strconv.Itoa(i), []byte("v"), time.Minute)
+	}
+
+	srv.restart(t)
+	f.check()
+	if !f.Healthy() {
+		t.Fatalf("not re-attached: %s", f.Status().LastError)
+	}
+	if _, ok := srv.value("untouched"); ok {
+		t.Error("database was not flushed")
+	}
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Entry is a value produced by a Loader together with its metadata
+type Entry struct {
+	Data        []byte
+	ContentType string
+	// Status is the upstream status code; cached entries are always 200
+	Status int
+	// NoStore returns the entry to the callers without caching it, e.g.
+	// for upstream errors
+	NoStore bool
+}
+
+// Loader produces a fresh entry on a cache miss
+type Loader func() (*Entry, error)
+
+// call is an in-flight load shared by every caller of the same key
+type call struct {
+	done  chan struct{}
+	entry *Entry
+	err   error
+}
+
+// flightGroup coalesces concurrent loads of the same key
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do runs fn once for all concurrent callers of key
+func (g *flightGroup) do(key string, fn Loader) (*Entry, error) {
+	cl, started := g.start(key, fn)
+	if started {
+		g.run(key, cl, fn)
+	} else {
+		<-cl.done
+	}
+	return cl.entry, cl.err
+}
+
+// doAsync runs fn in the background unless a load of key is in flight
+func (g *flightGroup) doAsync(key string, fn Loader) {
+	if cl, started := g.start(key, fn); started {
+		go g.run(key, cl, fn)
+	}
+}
+
+func (g *flightGroup) start(key string, fn Loader) (*call, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if cl, ok := g.calls[key]; ok {
+		return cl, false
+	}
+	cl := &call{done: make(chan struct{})}
+	g.calls[key] = cl
+	return cl, true
+}
+
+// run calls fn and wakes the waiters. A panic in fn is turned into the
+// error of the call, since background loads have no caller to recover it.
+func (g *flightGroup) run(key string, cl *call, fn Loader) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Cache load of %s panicked: %v\n%s", key, r, debug.Stack())
+			cl.entry, cl.err = nil, fmt.Errorf("load %s: panic: %v", key, r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(cl.done)
+	}()
+	cl.entry, cl.err = fn()
+}
+
+// Fetch returns the entry cached under key or loads it. Concurrent misses
+// of a key share a single load. Entries are fresh for ttl and kept for
+// another stale period during which they are returned immediately while
+// one background load refreshes them.
+func (c *Cache) Fetch(key string, ttl, stale time.Duration, load Loader) (*Entry, error) {
+	fill := func() (*Entry, error) {
+		entry, err := load()
+		if err == nil && entry != nil && !entry.NoStore {
+			c.Set(key, encodeEntry(entry, time.Now().Add(ttl)), ttl+stale)
+		}
+		return entry, err
+	}
+
+	// Values that do not decode are loaded again and overwritten
+	if raw, ok := c.Get(key); ok {
+		if entry, freshUntil, err := decodeEntry(raw); err == nil {
+			if time.Now().After(freshUntil) {
+				c.flight.doAsync(key, fill)
+			}
+			return entry, nil
+		}
+	}
+	return c.flight.do(key, fill)
+}
+
+// Stored entries start with entryMagic, the time they stay fresh until and
+// the length of the content type. Values without the magic, such as raw
+// bodies written under the same keys by older versions, are misses.
+const (
+	entryMagic      = "SVE1"
+	entryHeaderSize = len(entryMagic) + 8 + 2
+)
+
+var errBadEntry = errors.New("invalid cache entry")
+
+func encodeEntry(entry *Entry, freshUntil time.Time) []byte {
+	contentType := entry.ContentType
+	if len(contentType) > 0xFFFF {
+		contentType = ""
+	}
+	buf := make([]byte, entryHeaderSize+len(contentType)+len(entry.Data))
+	n := copy(buf, entryMagic)
+	binary.BigEndian.PutUint64(buf[n:], uint64(freshUntil.UnixNano()))
+	binary.BigEndian.PutUint16(buf[n+8:], uint16(len(contentType)))
+	n = copy(buf[entryHeaderSize:], contentType)
+	copy(buf[entryHeaderSize+n:], entry.Data)
+	return buf
+}
+
+func decodeEntry(raw []byte) (*Entry, time.Time, error) {
+	if len(raw) < entryHeaderSize || string(raw[:len(entryMagic)]) != entryMagic {
+		return nil, time.Time{}, errBadEntry
+	}
+	header := raw[len(entryMagic):]
+	freshUntil := time.Unix(0, int64(binary.BigEndian.Uint64(header)))
+	ctLen := int(binary.BigEndian.Uint16(header[8:]))
+	if len(raw) < entryHeaderSize+ctLen {
+		return nil, time.Time{}, errBadEntry
+	}
+	return &Entry{
+		ContentType: string(raw[entryHeaderSize : entryHeaderSize+ctLen]),
+		Data:        raw[entryHeaderSize+ctLen:],
+		Status:      http.StatusOK,
+	}, freshUntil, nil
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFetchRecoversLoaderPanic(t *testing.T) {
+	c := New(Options{})
+	defer c.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	panicking := func() (*Entry, error) {
+		close(started)
+		<-release
+		panic("boom")
+	}
+
+	// A waiter sharing the load gets the error as well
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, errs[0] = c.Fetch("key", time.Minute, 0, panicking)
+	}()
+	<-started
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, errs[1] = c.Fetch("key", time.Minute, 0, func() (*Entry, error) {
+			t.Error("second loader ran")
+			return nil, nil
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("caller %d got no error", i)
+		}
+	}
+}
+
+func TestBackgroundRefreshRecoversLoaderPanic(t *testing.T) {
+	c := New(Options{})
+	defer c.Close()
+
+	if _, err := c.Fetch("key", time.Millisecond, time.Minute, func() (*Entry, error) {
+		return &Entry{Data: []byte("old"), Status: 200}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	refreshed := make(chan struct{})
+	entry, err := c.Fetch("key", time.Millisecond, time.Minute, func() (*Entry, error) {
+		defer close(refreshed)
+		panic("boom")
+	})
+	if err != nil || string(entry.Data) != "old" {
+		t.Fatalf("stale entry = %v, %v", entry, err)
+	}
+	<-refreshed
+
+	// The failed refresh is not in flight anymore, so the next one runs
+	loaded := make(chan struct{})
+	deadline := time.After(5 * time.Second)
+	for {
+		c.Fetch("key", time.Millisecond, time.Minute, func() (*Entry, error) {
+			select {
+			case <-loaded:
+			default:
+				close(loaded)
+			}
+			return &Entry{Data: []byte("new"), Status: 200}, nil
+		})
+		select {
+		case <-loaded:
+			return
+		case <-deadline:
+			t.Fatal("refresh never ran again")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"snowy_viewer/internal/fsutil"
+)
+
+// imageIndexFile lists the cached URLs next to the blobs
+const imageIndexFile = "index.json"
+
+// ImageMeta describes a cached image
+type ImageMeta struct {
+	SHA256      string    `json:"sha256"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	FetchedAt   time.Time `json:"fetchedAt"`
+	LastAccess  time.Time `json:"lastAccess"`
+}
+
+// ImageStore is a content-addressed disk cache for immutable images. Blobs
+// are named by their SHA-256 so URLs serving identical bytes share one
+// file; the least recently used images are evicted above the size quota.
+type ImageStore struct {
+	dir      string
+	maxBytes int64
+
+	mu    sync.Mutex
+	index map[string]*ImageMeta // by URL
+	refs  map[string]int        // URLs per blob
+	size  int64                 // total size of distinct blobs
+	dirty bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewImageStore opens the image cache in dir, holding at most maxBytes
+// (unbounded when <= 0). The index is flushed to disk every flushInterval.
+func NewImageStore(dir string, maxBytes int64, flushInterval time.Duration) (*ImageStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &ImageStore{
+		dir:      dir,
+		maxBytes: maxBytes,
+		index:    make(map[string]*ImageMeta),
+		refs:     make(map[string]int),
+		stop:     make(chan struct{}),
+	}
+	s.load()
+	if flushInterval > 0 {
+		go s.flushLoop(flushInterval)
+	}
+	return s, nil
+}
+
+// blobPath returns the file of a blob, fanned out by its first two digits
+func (s *ImageStore) blobPath(sum string) string {
+	return filepath.Join(s.dir, sum[:2], sum)
+}
+
+// load reads the index, dropping entries whose blob is gone and blobs no
+// entry refers to
+func (s *ImageStore) load() {
+	content, err := os.ReadFile(filepath.Join(s.dir, imageIndexFile))
+	if err == nil {
+		if err := json.Unmarshal(content, &s.index); err != nil {
+			fmt.Printf("Warning: ignoring corrupt image cache index: %v\n", err)
+			s.index = make(map[string]*ImageMeta)
+		}
+	}
+	if s.index == nil {
+		s.index = make(map[string]*ImageMeta)
+	}
+
+	for url, meta := range s.index {
+		if len(meta.SHA256) != sha256.Size*2 {
+			delete(s.index, url)
+			continue
+		}
+		info, err := os.Stat(s.blobPath(meta.SHA256))
+		if err != nil {
+			delete(s.index, url)
+			continue
+		}
+		meta.Size = info.Size()
+		if s.refs[meta.SHA256] == 0 {
+			s.size += meta.Size
+		}
+		s.refs[meta.SHA256]++
+	}
+
+	// Remove orphaned blobs and interrupted writes
+	dirs, _ := os.ReadDir(s.dir)
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		files, _ := os.ReadDir(filepath.Join(s.dir, d.Name()))
+		for _, f := range files {
+			if s.refs[f.Name()] == 0 {
+				os.Remove(filepath.Join(s.dir, d.Name(), f.Name()))
+			}
+		}
+	}
+	s.evict()
+}
+
+// Get returns the cached image of url
+func (s *ImageStore) Get(url string) (*Entry, bool) {
+	s.mu.Lock()
+	meta, ok := s.index[url]
+	var sum, contentType string
+	if ok {
+		meta.LastAccess = time.Now()
+		s.dirty = true
+		sum, contentType = meta.SHA256, meta.ContentType
+	}
+	s.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(s.blobPath(sum))
+	if err != nil {
+		s.mu.Lock()
+		if current, ok := s.index[url]; ok && current.SHA256 == sum {
+			s.drop(url)
+		}
+		s.mu.Unlock()
+		return nil, false
+	}
+	return &Entry{Data: data, ContentType: contentType, Status: http.StatusOK}, true
+}
+
+// Put stores the image of url and evicts old images above the quota
+func (s *ImageStore) Put(url string, entry *Entry) error {
+	if s.maxBytes > 0 && int64(len(entry.Data)) > s.maxBytes {
+		return nil
+	}
+	hash := sha256.Sum256(entry.Data)
+	sum := hex.EncodeToString(hash[:])
+	path := s.blobPath(sum)
+
+	// Blobs are content-addressed, so the slow synced write happens
+	// before taking the lock
+	if _, err := os.Stat(path); err != nil {
+		if err := writeBlob(path, entry.Data); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refs[sum] == 0 {
+		// Dropping the last reference elsewhere may have removed the
+		// blob since it was written
+		if _, err := os.Stat(path); err != nil {
+			if err := writeBlob(path, entry.Data); err != nil {
+				return err
+			}
+		}
+		s.size += int64(len(entry.Data))
+	}
+	// The new reference is taken before the previous entry is dropped, so
+	// a blob they share stays in place
+	s.refs[sum]++
+	if _, ok := s.index[url]; ok {
+		s.drop(url)
+	}
+	now := time.Now()
+	s.index[url] = &ImageMeta{
+		SHA256:      sum,
+		ContentType: entry.ContentType,
+		Size:        int64(len(entry.Data)),
+		FetchedAt:   now,
+		LastAccess:  now,
+	}
+	s.dirty = true
+	s.evict()
+	return nil
+}
+
+// writeBlob stores the data of a blob
+func writeBlob(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data, 0o644)
+}
+
+// drop removes an entry and its blob once unreferenced; s.mu must be held
+func (s *ImageStore) drop(url string) {
+	meta := s.index[url]
+	delete(s.index, url)
+	s.dirty = true
+	s.refs[meta.SHA256]--
+	if s.refs[meta.SHA256] > 0 {
+		return
+	}
+	delete(s.refs, meta.SHA256)
+	s.size -= meta.Size
+	os.Remove(s.blobPath(meta.SHA256))
+}
+
+// evict drops the least recently used images above the quota; s.mu must
+// be held
+func (s *ImageStore) evict() {
+	if s.maxBytes <= 0 || s.size <= s.maxBytes {
+		return
+	}
+	urls := make([]string, 0, len(s.index))
+	for url := range s.index {
+		urls = append(urls, url)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		return s.index[urls[i]].LastAccess.Before(s.index[urls[j]].LastAccess)
+	})
+	for _, url := range urls {
+		if s.size <= s.maxBytes {
+			break
+		}
+		s.drop(url)
+	}
+}
+
+// Usage returns the number of cached URLs and the size of their blobs
+func (s *ImageStore) Usage() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.index), s.size
+}
+
+// Flush writes the index if it changed
+func (s *ImageStore) Flush() error {
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	content, err := json.Marshal(s.index)
+	s.dirty = false
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(filepath.Join(s.dir, imageIndexFile), content, 0o644)
+}
+
+func (s *ImageStore) flushLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				fmt.Printf("Warning: failed to write image cache index: %v\n", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close stops the flush loop and writes the index
+func (s *ImageStore) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	return s.Flush()
+}
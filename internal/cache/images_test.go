@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func newTestImageStore(t *testing.T, maxBytes int64) *ImageStore {
+	t.Helper()
+	s, err := NewImageStore(t.TempDir(), maxBytes, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestImageStoreSharesBlobs(t *testing.T) {
+	s := newTestImageStore(t, 0)
+	png := &Entry{Data: []byte("png"), ContentType: "image/png"}
+	for _, url := range []string{"a", "b", "a"} {
+		if err := s.Put(url, png); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.size != 3 || len(s.refs) != 1 {
+		t.Errorf("size %d refs %v, want one blob of 3 bytes", s.size, s.refs)
+	}
+
+	// Replacing b keeps the blob a still uses
+	if err := s.Put("b", &Entry{Data: []byte("jpeg"), ContentType: "image/jpeg"}); err != nil {
+		t.Fatal(err)
+	}
+	for url, want := range map[string]string{"a": "png", "b": "jpeg"} {
+		if entry, ok := s.Get(url); !ok || string(entry.Data) != want {
+			t.Errorf("Get(%s) = %v, %v, want %s", url, entry, ok, want)
+		}
+	}
+	if s.size != 7 {
+		t.Errorf("size = %d, want 7", s.size)
+	}
+}
+
+func TestImageStoreConcurrentPuts(t *testing.T) {
+	s := newTestImageStore(t, 64)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				url := fmt.Sprintf("u%d", (i+j)%5)
+				data := []byte(fmt.Sprintf("image-%d", j%3))
+				if err := s.Put(url, &Entry{Data: data, ContentType: "image/png"}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Every indexed image is readable and the accounting matches the blobs
+	s.mu.Lock()
+	urls := make([]string, 0, len(s.index))
+	for url := range s.index {
+		urls = append(urls, url)
+	}
+	var size int64
+	refs := make(map[string]int)
+	for _, meta := range s.index {
+		if refs[meta.SHA256] == 0 {
+			size += meta.Size
+		}
+		refs[meta.SHA256]++
+	}
+	if size != s.size || fmt.Sprint(refs) != fmt.Sprint(s.refs) {
+		t.Errorf("size %d refs %v, index implies %d %v", s.size, s.refs, size, refs)
+	}
+	s.mu.Unlock()
+	for _, url := range urls {
+		if _, ok := s.Get(url); !ok {
+			t.Errorf("image %s lost its blob", url)
+		}
+	}
+}
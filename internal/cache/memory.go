@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// memoryEntry is an LRU list element
+type memoryEntry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+// Memory is an in-process LRU cache bounded by the total size of its
+// values. Expired entries are removed on access and by a background
+// janitor.
+type Memory struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	lru      *list.List // front is most recently used
+	items    map[string]*list.Element
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMemory creates a memory backend holding at most maxBytes of values
+// (unbounded when <= 0) and starts its janitor
+func NewMemory(maxBytes int64, janitorInterval time.Duration) *Memory {
+	m := &Memory{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+		stop:     make(chan struct{}),
+	}
+	if janitorInterval > 0 {
+		go m.janitor(janitorInterval)
+	}
+	return m
+}
+
+func (m *Memory) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*memoryEntry)
+	if time.Now().After(entry.expiresAt) {
+		m.remove(el)
+		return nil, false
+	}
+	m.lru.MoveToFront(el)
+	return entry.data, true
+}
+
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		m.remove(el)
+	}
+	// A value larger than the whole budget would only evict everything else
+	if m.maxBytes > 0 && int64(len(value)) > m.maxBytes {
+		return nil
+	}
+	m.items[key] = m.lru.PushFront(&memoryEntry{
+		key:       key,
+		data:      value,
+		expiresAt: time.Now().Add(ttl),
+	})
+	m.size += int64(len(value))
+	for m.maxBytes > 0 && m.size > m.maxBytes {
+		m.remove(m.lru.Back())
+	}
+	return nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		m.remove(el)
+	}
+	return nil
+}
+
+// TTL returns the remaining lifetime of an entry
+func (m *Memory) TTL(key string) (time.Duration, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.items[key]
+	if !ok {
+		return 0, false
+	}
+	ttl := time.Until(el.Value.(*memoryEntry).expiresAt)
+	return ttl, ttl > 0
+}
+
+// Close stops the janitor
+func (m *Memory) Close() error {
+	m.stopOnce.Do(func() { close(m.stop) })
+	return nil
+}
+
+// Size returns the number of entries and their total size in bytes
+func (m *Memory) Size() (int, int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.items), m.size
+}
+
+// remove drops an element; m.mu must be held
+func (m *Memory) remove(el *list.Element) {
+	entry := m.lru.Remove(el).(*memoryEntry)
+	delete(m.items, entry.key)
+	m.size -= int64(len(entry.data))
+}
+
+func (m *Memory) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.deleteExpired()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+func (m *Memory) deleteExpired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for el := m.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if now.After(el.Value.(*memoryEntry).expiresAt) {
+			m.remove(el)
+		}
+		el = prev
+	}
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ctx = context.Background()
+
+// Redis is a backend on a Redis server
+type Redis struct {
+	client *redis.Client
+	addr   string
+}
+
+// NewRedis connects to redisURL, either a redis:// URL or a plain address,
+// and verifies the connection
+func NewRedis(redisURL string) (*Redis, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		// Fallback: treat as direct address
+		opts = &redis.Options{
+			Addr: redisURL,
+		}
+	}
+
+	client := redis.NewClient(opts)
+
+	// Test connection with timeout
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &Redis{client: client, addr: opts.Addr}, nil
+}
+
+// Addr returns the server address without credentials
+func (r *Redis) Addr() string {
+	return r.addr
+}
+
+func (r *Redis) Get(key string) ([]byte, bool) {
+	val, err := r.get(key)
+	return val, err == nil
+}
+
+// get distinguishes a missing key (redis.Nil) from connection failures
+func (r *Redis) get(key string) ([]byte, error) {
+	return r.client.Get(ctx, key).Bytes()
+}
+
+// Ping checks the connection
+func (r *Redis) Ping() error {
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	return r.client.Ping(pingCtx).Err()
+}
+
+func (r *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *Redis) Delete(key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+// TTL returns the remaining lifetime of a key
+func (r *Redis) TTL(key string) (time.Duration, bool) {
+	ttl, err := r.client.TTL(ctx, key).Result()
+	if err != nil || ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// deleteKeys removes keys in batches
+func (r *Redis) deleteKeys(keys []string) error {
+	const batch = 500
+	for len(keys) > 0 {
+		n := min(len(keys), batch)
+		if err := r.client.Del(ctx, keys[:n]...).Err(); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// flush removes every key of the selected database
+func (r *Redis) flush() error {
+	return r.client.FlushDB(ctx).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
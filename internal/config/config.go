@@ -1,24 +1,80 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	RedisURL         string
-	BilibiliSessData string
-	BilibiliCookie   string
-	Port             string
-	MasterDataPath   string
+	RedisURL             string
+	CacheMemoryMB        int
+	CacheDir             string
+	ImageCacheDir        string
+	ImageCacheMB         int
+	ImageProxyHosts      []string
+	ImageProxyMaxMB      int
+	BilibiliSessData     string
+	BilibiliCookie       string
+	BilibiliCredentials  string
+	BilibiliArchiveDir   string
+	BilibiliTimelineUIDs []string
+	BilibiliRateLimit    int
+	BilibiliRateBurst    int
+	BilibiliBreakerFails int
+	BilibiliBreakerOpen  time.Duration
+	WatchUIDs            []string
+	WatchInterval        time.Duration
+	WebhooksFile         string
+	WebhookURLs          []string
+	WebhookSecret        string
+	WebhookTemplate      string
+	PublicBaseURL        string
+	Port                 string
+	MasterDataPath       string
+	MasterServers        []string
+	MasterUpdateInterval time.Duration
+	MasterFetchRetries   int
+	MasterSourcesFile    string
+	AdminToken           string
+	AdminPort            string
 }
 
 func Load() *Config {
 	cfg := &Config{
-		RedisURL:         getEnv("REDIS_URL", "localhost:6379"),
-		BilibiliSessData: os.Getenv("BILIBILI_SESSDATA"),
-		BilibiliCookie:   os.Getenv("BILIBILI_COOKIE"),
-		Port:             getEnv("PORT", "8080"),
-		MasterDataPath:   getEnv("MASTER_DATA_PATH", "./data/master"),
+		RedisURL:             getEnv("REDIS_URL", "localhost:6379"),
+		CacheMemoryMB:        getEnvInt("CACHE_MEMORY_MB", 256),
+		CacheDir:             os.Getenv("CACHE_DIR"),
+		ImageCacheDir:        getEnv("IMAGE_CACHE_DIR", "./data/images"),
+		ImageCacheMB:         getEnvInt("IMAGE_CACHE_MB", 1024),
+		ImageProxyHosts:      getEnvList("IMAGE_PROXY_HOSTS", "hdslb.com,biliimg.com"),
+		ImageProxyMaxMB:      getEnvInt("IMAGE_PROXY_MAX_MB", 20),
+		BilibiliSessData:     os.Getenv("BILIBILI_SESSDATA"),
+		BilibiliCookie:       os.Getenv("BILIBILI_COOKIE"),
+		BilibiliCredentials:  getEnv("BILIBILI_CREDENTIALS_FILE", "./data/bilibili/credentials.json"),
+		BilibiliArchiveDir:   getEnv("BILIBILI_ARCHIVE_DIR", "./data/bilibili"),
+		BilibiliTimelineUIDs: getEnvList("BILIBILI_TIMELINE_UIDS", ""),
+		BilibiliRateLimit:    getEnvInt("BILIBILI_RATE_LIMIT", 60),
+		BilibiliRateBurst:    getEnvInt("BILIBILI_RATE_BURST", 10),
+		BilibiliBreakerFails: getEnvInt("BILIBILI_BREAKER_FAILURES", 5),
+		BilibiliBreakerOpen:  getEnvDuration("BILIBILI_BREAKER_COOLDOWN", time.Minute),
+		WatchUIDs:            getEnvList("WATCH_UIDS", ""),
+		WatchInterval:        getEnvDuration("WATCH_INTERVAL", 5*time.Minute),
+		WebhooksFile:         os.Getenv("WEBHOOKS_FILE"),
+		WebhookURLs:          getEnvList("WEBHOOK_URLS", ""),
+		WebhookSecret:        os.Getenv("WEBHOOK_SECRET"),
+		WebhookTemplate:      os.Getenv("WEBHOOK_TEMPLATE"),
+		PublicBaseURL:        strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"),
+		Port:                 getEnv("PORT", "8080"),
+		MasterDataPath:       getEnv("MASTER_DATA_PATH", "./data/master"),
+		MasterServers:        getEnvList("MASTER_SERVERS", "jp,cn,tw"),
+		MasterUpdateInterval: getEnvDuration("MASTER_UPDATE_INTERVAL", 30*time.Minute),
+		MasterFetchRetries:   getEnvInt("MASTER_FETCH_RETRIES", 3),
+		MasterSourcesFile:    os.Getenv("MASTER_SOURCES_FILE"),
+		AdminToken:           os.Getenv("ADMIN_TOKEN"),
+		AdminPort:            os.Getenv("ADMIN_PORT"),
 	}
 	return cfg
 }
@@ -29,3 +85,39 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		fmt.Printf("Warning: invalid %s %q, using %s\n", key, v, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		fmt.Printf("Warning: invalid %s %q, using %d\n", key, v, defaultValue)
+		return defaultValue
+	}
+	return n
+}
@@ -0,0 +1,311 @@
+// Package feed renders syndication feeds as RSS 2.0, Atom and JSON Feed
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"time"
+)
+
+// Content types of the rendered formats
+const (
+	ContentTypeRSS  = "application/rss+xml; charset=utf-8"
+	ContentTypeAtom = "application/atom+xml; charset=utf-8"
+	ContentTypeJSON = "application/feed+json; charset=utf-8"
+)
+
+// Feed is a format independent feed
+type Feed struct {
+	ID          string // stable identifier, used as the Atom feed id
+	Title       string
+	Link        string // the page the feed is about
+	FeedURL     string // where the feed itself is served
+	Description string
+	Icon        string
+	Author      Author
+	Updated     time.Time
+	Items       []Item
+}
+
+type Author struct {
+	Name   string
+	URL    string
+	Avatar string
+}
+
+// Item is one entry of a feed
+type Item struct {
+	ID          string // stable GUID; never changes once published
+	Title       string
+	Link        string
+	ContentHTML string
+	ContentText string
+	Image       string
+	ImageType   string // MIME type of Image, needed for RSS enclosures
+	Published   time.Time
+	Author      Author
+}
+
+// updated returns the time of the newest change to the feed
+func (f *Feed) updated() time.Time {
+	updated := f.Updated
+	for _, item := range f.Items {
+		if item.Published.After(updated) {
+			updated = item.Published
+		}
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	return updated.UTC()
+}
+
+// RSS 2.0
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      *atomLink `xml:"atom:link,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Image         *rssImage `xml:"image,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssImage struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link,omitempty"`
+	Description string        `xml:"description"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int    `xml:"length,attr"`
+}
+
+// RSS renders the feed as RSS 2.0
+func (f *Feed) RSS() ([]byte, error) {
+	channel := rssChannel{
+		Title:         f.Title,
+		Link:          f.Link,
+		Description:   f.Description,
+		LastBuildDate: f.updated().Format(time.RFC1123Z),
+		Items:         make([]rssItem, 0, len(f.Items)),
+	}
+	if channel.Description == "" {
+		channel.Description = f.Title
+	}
+	if f.FeedURL != "" {
+		channel.AtomLink = &atomLink{Href: f.FeedURL, Rel: "self", Type: "application/rss+xml"}
+	}
+	if f.Icon != "" {
+		channel.Image = &rssImage{URL: f.Icon, Title: f.Title, Link: f.Link}
+	}
+	for _, item := range f.Items {
+		entry := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.ContentHTML,
+			GUID:        rssGUID{IsPermaLink: item.ID == item.Link, Value: item.ID},
+			PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+		}
+		if entry.Description == "" {
+			entry.Description = item.ContentText
+		}
+		if item.Image != "" && item.ImageType != "" {
+			// The length is unknown until the image is fetched; 0 is the
+			// accepted placeholder
+			entry.Enclosure = &rssEnclosure{URL: item.Image, Type: item.ImageType}
+		}
+		channel.Items = append(channel.Items, entry)
+	}
+	return marshalXML(rssDocument{Version: "2.0", AtomNS: atomNamespace, Channel: channel})
+}
+
+// Atom
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	NS      string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Icon    string      `xml:"icon,omitempty"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+	Links     []atomLink  `xml:"link"`
+	Author    *atomAuthor `xml:"author,omitempty"`
+	Content   *atomText   `xml:"content,omitempty"`
+}
+
+// Atom renders the feed as Atom 1.0
+func (f *Feed) Atom() ([]byte, error) {
+	doc := atomFeed{
+		NS:      atomNamespace,
+		ID:      f.ID,
+		Title:   f.Title,
+		Updated: f.updated().Format(time.RFC3339),
+		Icon:    f.Icon,
+		Entries: make([]atomEntry, 0, len(f.Items)),
+	}
+	if doc.ID == "" {
+		doc.ID = f.Link
+	}
+	if f.Link != "" {
+		doc.Links = append(doc.Links, atomLink{Href: f.Link, Rel: "alternate", Type: "text/html"})
+	}
+	if f.FeedURL != "" {
+		doc.Links = append(doc.Links, atomLink{Href: f.FeedURL, Rel: "self", Type: "application/atom+xml"})
+	}
+	// Atom requires an author on the feed or on every entry
+	doc.Author = &atomAuthor{Name: f.Author.Name, URI: f.Author.URL}
+	if doc.Author.Name == "" {
+		doc.Author.Name = f.Title
+	}
+
+	for _, item := range f.Items {
+		published := item.Published.UTC().Format(time.RFC3339)
+		entry := atomEntry{
+			ID:        item.ID,
+			Title:     item.Title,
+			Updated:   published,
+			Published: published,
+		}
+		if item.Link != "" {
+			entry.Links = []atomLink{{Href: item.Link, Rel: "alternate", Type: "text/html"}}
+		}
+		if item.Author.Name != "" && item.Author.Name != f.Author.Name {
+			entry.Author = &atomAuthor{Name: item.Author.Name, URI: item.Author.URL}
+		}
+		switch {
+		case item.ContentHTML != "":
+			entry.Content = &atomText{Type: "html", Value: item.ContentHTML}
+		case item.ContentText != "":
+			entry.Content = &atomText{Type: "text", Value: item.ContentText}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshalXML(doc)
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// JSON Feed 1.1
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Icon        string           `json:"icon,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name   string `json:"name,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url,omitempty"`
+	Title         string           `json:"title,omitempty"`
+	ContentHTML   string           `json:"content_html,omitempty"`
+	ContentText   string           `json:"content_text,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	DatePublished string           `json:"date_published"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+}
+
+// JSON renders the feed as JSON Feed 1.1
+func (f *Feed) JSON() ([]byte, error) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.FeedURL,
+		Description: f.Description,
+		Icon:        f.Icon,
+		Items:       make([]jsonFeedItem, 0, len(f.Items)),
+	}
+	if f.Author.Name != "" {
+		doc.Authors = []jsonFeedAuthor{jsonAuthor(f.Author)}
+	}
+	for _, item := range f.Items {
+		entry := jsonFeedItem{
+			ID:            item.ID,
+			URL:           item.Link,
+			Title:         item.Title,
+			ContentHTML:   item.ContentHTML,
+			ContentText:   item.ContentText,
+			Image:         item.Image,
+			DatePublished: item.Published.UTC().Format(time.RFC3339),
+		}
+		if item.Author.Name != "" && item.Author.Name != f.Author.Name {
+			entry.Authors = []jsonFeedAuthor{jsonAuthor(item.Author)}
+		}
+		doc.Items = append(doc.Items, entry)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func jsonAuthor(a Author) jsonFeedAuthor {
+	return jsonFeedAuthor{Name: a.Name, URL: a.URL, Avatar: a.Avatar}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+
+	"snowy_viewer/internal/masterdata"
+)
+
+// RegisterAdminRoutes registers the admin routes. Authentication is left
+// to the caller so the routes can be mounted on any listener.
+func (h *Handler) RegisterAdminRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/masterdata/status", h.handleAdminMasterDataStatus)
+	mux.HandleFunc("/admin/masterdata/reload", h.handleAdminMasterDataReload)
+	mux.HandleFunc("/admin/masterdata/rollback", h.handleAdminMasterDataRollback)
+	mux.HandleFunc("/admin/bilibili/backfill", h.handleAdminBilibiliBackfill)
+	mux.HandleFunc("/admin/bilibili/credentials", h.handleAdminBilibiliCredentials)
+}
+
+// adminStores returns the stores selected by ?server=, or all stores
+func (h *Handler) adminStores(w http.ResponseWriter, r *http.Request) ([]*masterdata.Store, bool) {
+	server := r.URL.Query().Get("server")
+	if server == "" {
+		var stores []*masterdata.Store
+		for _, s := range h.stores.Servers() {
+			store, _ := h.stores.Get(s)
+			stores = append(stores, store)
+		}
+		return stores, true
+	}
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return nil, false
+	}
+	return []*masterdata.Store{store}, true
+}
+
+func (h *Handler) handleAdminMasterDataStatus(w http.ResponseWriter, r *http.Request) {
+	stores, ok := h.adminStores(w, r)
+	if !ok {
+		return
+	}
+	statuses := make([]masterdata.DetailedStatus, len(stores))
+	for i, store := range stores {
+		statuses[i] = store.DetailedStatus()
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (h *Handler) handleAdminMasterDataReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	stores, ok := h.adminStores(w, r)
+	if !ok {
+		return
+	}
+
+	status := http.StatusOK
+	statuses := make([]masterdata.DetailedStatus, len(stores))
+	for i, store := range stores {
+		if err := store.Fetch(); err != nil {
+			status = http.StatusBadGateway
+		}
+		statuses[i] = store.DetailedStatus()
+	}
+	writeJSON(w, status, statuses)
+}
+
+func (h *Handler) handleAdminMasterDataRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if r.URL.Query().Get("server") == "" {
+		writeError(w, http.StatusBadRequest, "Missing server parameter")
+		return
+	}
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+	if err := store.Rollback(); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, store.DetailedStatus())
+}
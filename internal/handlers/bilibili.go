@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"snowy_viewer/internal/bilibili"
+)
+
+// archiveResponse is a page of the local feed archive
+type archiveResponse struct {
+	bilibili.ArchiveState
+	Total int                `json:"total"`
+	Page  int                `json:"page"`
+	Limit int                `json:"limit"`
+	Items []bilibili.Dynamic `json:"items"`
+}
+
+func (h *Handler) handleBilibiliArchive(w http.ResponseWriter, r *http.Request) {
+	uid := strings.TrimPrefix(strings.TrimSuffix(r.URL.Path, "/"), "/api/bilibili/archive/")
+	if !bilibili.ValidUID(uid) {
+		writeError(w, http.StatusBadRequest, "Invalid UID")
+		return
+	}
+
+	paging := parsePagination(r.URL.Query(), 20)
+	items, state, total, err := h.bilibili.Archive().Items(uid, (paging.page-1)*paging.limit, paging.limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if items == nil {
+		items = []bilibili.Dynamic{}
+	}
+
+	writeJSON(w, http.StatusOK, archiveResponse{
+		ArchiveState: state,
+		Total:        total,
+		Page:         paging.page,
+		Limit:        paging.limit,
+		Items:        items,
+	})
+}
+
+// handleAdminBilibiliBackfill starts walking ?pages= older pages of ?uid=
+// into the archive in the background
+func (h *Handler) handleAdminBilibiliBackfill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	uid := r.URL.Query().Get("uid")
+	if !bilibili.ValidUID(uid) {
+		writeError(w, http.StatusBadRequest, "Invalid UID")
+		return
+	}
+	pages, err := strconv.Atoi(r.URL.Query().Get("pages"))
+	if err != nil || pages < 1 {
+		pages = 50
+	}
+
+	state, err := h.bilibili.Archive().State(uid)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if state.Running {
+		writeError(w, http.StatusConflict, bilibili.ErrBackfillRunning.Error())
+		return
+	}
+
+	go func() {
+		if err := h.bilibili.Backfill(uid, pages); err != nil && !errors.Is(err, bilibili.ErrBackfillRunning) {
+			fmt.Printf("Bilibili backfill %s failed: %v\n", uid, err)
+		}
+	}()
+	state.Running = true
+	writeJSON(w, http.StatusAccepted, state)
+}
+
+// timelineResponse is a page of the merged timeline
+type timelineResponse struct {
+	Total     int                       `json:"total"`
+	Page      int                       `json:"page"`
+	Limit     int                       `json:"limit"`
+	FetchedAt int64                     `json:"fetchedAt"`
+	Sources   []bilibili.TimelineSource `json:"sources"`
+	Items     []bilibili.Dynamic        `json:"items"`
+}
+
+// handleBilibiliTimeline merges the newest dynamics of the configured
+// accounts. ?uids= narrows the accounts and ?types= the dynamic types.
+func (h *Handler) handleBilibiliTimeline(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	uids := h.bilibili.TimelineUIDs()
+	if len(uids) == 0 {
+		writeError(w, http.StatusNotFound, "Timeline not configured")
+		return
+	}
+	if selected := parseStringSet(query, "uids"); selected != nil {
+		var narrowed []string
+		for _, uid := range uids {
+			if selected[uid] {
+				narrowed = append(narrowed, uid)
+			}
+		}
+		if len(narrowed) != len(selected) {
+			writeError(w, http.StatusBadRequest, "uids must be configured timeline accounts")
+			return
+		}
+		uids = narrowed
+	}
+	types := parseStringSet(query, "types")
+
+	timeline := h.bilibili.FetchTimeline(uids, types)
+	failed := 0
+	for _, source := range timeline.Sources {
+		if !source.OK {
+			failed++
+		}
+	}
+	if failed == len(timeline.Sources) {
+		writeError(w, http.StatusBadGateway, "All timeline accounts failed")
+		return
+	}
+
+	paging := parsePagination(query, 20)
+	start, end := paging.bounds(len(timeline.Items))
+	writeJSON(w, http.StatusOK, timelineResponse{
+		Total:     len(timeline.Items),
+		Page:      paging.page,
+		Limit:     paging.limit,
+		FetchedAt: timeline.FetchedAt,
+		Sources:   timeline.Sources,
+		Items:     timeline.Items[start:end],
+	})
+}
+
+func (h *Handler) handleBilibiliLive(w http.ResponseWriter, r *http.Request) {
+	uid := strings.TrimPrefix(strings.TrimSuffix(r.URL.Path, "/"), "/api/bilibili/live/")
+	if !bilibili.ValidUID(uid) {
+		writeError(w, http.StatusBadRequest, "Invalid UID")
+		return
+	}
+	room, statusCode, err := h.bilibili.FetchLive(uid)
+	if err != nil {
+		writeError(w, statusCode, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, room)
+}
+
+func (h *Handler) handleBilibiliVideo(w http.ResponseWriter, r *http.Request) {
+	bvid := strings.TrimPrefix(strings.TrimSuffix(r.URL.Path, "/"), "/api/bilibili/video/")
+	if !bilibili.ValidBVID(bvid) {
+		writeError(w, http.StatusBadRequest, "Invalid BVID")
+		return
+	}
+	video, statusCode, err := h.bilibili.FetchVideo(bvid)
+	if err != nil {
+		writeError(w, statusCode, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, video)
+}
+
+// handleBilibiliStatus reports the rate limiter and circuit breaker of
+// each Bilibili host
+func (h *Handler) handleBilibiliStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.bilibili.UpstreamStatus())
+}
+
+// handleAdminBilibiliCredentials manages the credential pool. GET lists
+// the accounts, PUT replaces all of them with a JSON array, POST adds or
+// replaces one account and DELETE ?name= removes one.
+func (h *Handler) handleAdminBilibiliCredentials(w http.ResponseWriter, r *http.Request) {
+	pool := h.bilibili.Credentials()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var inputs []bilibili.CredentialInput
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&inputs); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+		if err := pool.Replace(inputs); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case http.MethodPost:
+		var input bilibili.CredentialInput
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&input); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+		if err := pool.Put(input); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case http.MethodDelete:
+		if !pool.Remove(r.URL.Query().Get("name")) {
+			writeError(w, http.StatusNotFound, "Credential not found")
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, pool.Status())
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"snowy_viewer/internal/masterdata"
+	"snowy_viewer/internal/models"
+)
+
+// unitCharacters maps unit identifiers, in master data and short form, to
+// the characters belonging to the unit
+var unitCharacters = map[string][]int{
+	"light_sound":    {1, 2, 3, 4},
+	"ln":             {1, 2, 3, 4},
+	"idol":           {5, 6, 7, 8},
+	"mmj":            {5, 6, 7, 8},
+	"street":         {9, 10, 11, 12},
+	"vbs":            {9, 10, 11, 12},
+	"theme_park":     {13, 14, 15, 16},
+	"ws":             {13, 14, 15, 16},
+	"school_refusal": {17, 18, 19, 20},
+	"25ji":           {17, 18, 19, 20},
+	"piapro":         {21, 22, 23, 24, 25, 26},
+	"vs":             {21, 22, 23, 24, 25, 26},
+}
+
+// unitAliases maps short unit identifiers to master data support units
+var unitAliases = map[string]string{
+	"ln":   "light_sound",
+	"mmj":  "idol",
+	"vbs":  "street",
+	"ws":   "theme_park",
+	"25ji": "school_refusal",
+	"vs":   "piapro",
+}
+
+func rarityOrder(rarity string) int {
+	if rarity == "rarity_birthday" {
+		return 5
+	}
+	n, _ := strconv.Atoi(strings.TrimPrefix(rarity, "rarity_"))
+	return n
+}
+
+func (h *Handler) handleCardRoutes(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 4:
+		h.handleCardDetail(w, r)
+	case len(parts) == 5 && parts[4] == "costumes":
+		h.handleCardCostumes(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// cardLookups holds the maps card list items are built from, read once
+// per request
+type cardLookups struct {
+	supplyTypes map[int]string
+	gachas      map[int][]models.GachaInfo
+	events      map[int]models.EventInfo
+}
+
+func newCardLookups(store *masterdata.Store) cardLookups {
+	return cardLookups{
+		supplyTypes: store.GetCardSupplyTypeMap(),
+		gachas:      store.GetCardGachaMap(),
+		events:      store.GetCardEventMap(),
+	}
+}
+
+// cardListItem builds the list representation of a card
+func cardListItem(lookups cardLookups, c models.Card) models.CardListItem {
+	item := models.CardListItem{
+		Card:           c,
+		CardSupplyType: lookups.supplyTypes[c.CardSupplyID],
+		Gachas:         lookups.gachas[c.ID],
+	}
+	if item.CardSupplyType == "" {
+		item.CardSupplyType = "normal"
+	}
+	if ev, ok := lookups.events[c.ID]; ok {
+		item.Event = &ev
+	}
+	if item.Gachas == nil {
+		item.Gachas = []models.GachaInfo{}
+	}
+	return item
+}
+
+func (h *Handler) handleCardList(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse Params
+	query := r.URL.Query()
+	paging := parsePagination(query, 30)
+	characters := parseIntSet(query, "characters")
+	units := parseStringSet(query, "units")
+	attrs := parseStringSet(query, "attrs")
+	rarities := parseStringSet(query, "rarities")
+	supplyTypes := parseStringSet(query, "supplyTypes")
+	supportUnits := parseStringSet(query, "supportUnits")
+	releaseFrom, hasReleaseFrom := parseInt64(query, "releaseFrom")
+	releaseTo, hasReleaseTo := parseInt64(query, "releaseTo")
+	search := strings.ToLower(strings.TrimSpace(query.Get("search")))
+	sortBy := query.Get("sortBy")
+	sortOrder := query.Get("sortOrder")
+
+	unitMembers := make(map[int]bool)
+	unitSupport := make(map[string]bool)
+	for unit := range units {
+		for _, id := range unitCharacters[unit] {
+			unitMembers[id] = true
+		}
+		if alias, ok := unitAliases[unit]; ok {
+			unit = alias
+		}
+		unitSupport[unit] = true
+	}
+
+	cardList := store.GetCardList()
+	lookups := newCardLookups(store)
+
+	// Filter
+	searchId, searchIdErr := strconv.Atoi(search)
+	var filtered []models.Card
+	for _, c := range cardList {
+		if characters != nil && !characters[c.CharacterID] {
+			continue
+		}
+		if units != nil && !unitMembers[c.CharacterID] && !unitSupport[c.SupportUnit] {
+			continue
+		}
+		if attrs != nil && !attrs[c.Attr] {
+			continue
+		}
+		if rarities != nil && !rarities[c.CardRarityType] {
+			continue
+		}
+		if supplyTypes != nil {
+			supplyType := lookups.supplyTypes[c.CardSupplyID]
+			if supplyType == "" {
+				supplyType = "normal"
+			}
+			if !supplyTypes[supplyType] {
+				continue
+			}
+		}
+		// Support units only narrow down virtual singer cards
+		if supportUnits != nil && c.CharacterID >= 21 && !supportUnits[c.SupportUnit] {
+			continue
+		}
+		if hasReleaseFrom && c.ReleaseAt < releaseFrom {
+			continue
+		}
+		if hasReleaseTo && c.ReleaseAt > releaseTo {
+			continue
+		}
+		if search != "" && !(searchIdErr == nil && c.ID == searchId) &&
+			!strings.Contains(strings.ToLower(c.Prefix), search) &&
+			!strings.Contains(strings.ToLower(c.CardSkillName), search) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+
+	// Sort
+	sort.SliceStable(filtered, func(i, j int) bool {
+		a, b := filtered[i], filtered[j]
+		var less bool
+		switch sortBy {
+		case "releaseAt":
+			less = a.ReleaseAt < b.ReleaseAt || (a.ReleaseAt == b.ReleaseAt && a.ID < b.ID)
+		case "rarity":
+			ra, rb := rarityOrder(a.CardRarityType), rarityOrder(b.CardRarityType)
+			less = ra < rb || (ra == rb && a.ID < b.ID)
+		default:
+			less = a.ID < b.ID
+		}
+		if sortOrder == "asc" {
+			return less
+		}
+		return !less
+	})
+
+	// Paginate
+	total := len(filtered)
+	start, end := paging.bounds(total)
+	paged := filtered[start:end]
+
+	// Map to Response
+	resultItems := make([]models.CardListItem, len(paged))
+	for i, c := range paged {
+		// Level parameters are only part of the detail response
+		c.CardParameters = nil
+		resultItems[i] = cardListItem(lookups, c)
+	}
+
+	writeJSON(w, http.StatusOK, models.CardListResponse{
+		Total: total,
+		Page:  paging.page,
+		Limit: paging.limit,
+		Cards: resultItems,
+	})
+}
+
+func (h *Handler) handleCardDetail(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	id, err := strconv.Atoi(parts[3])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+
+	var found *models.Card
+	cardList := store.GetCardList()
+	for i := range cardList {
+		if cardList[i].ID == id {
+			found = &cardList[i]
+			break
+		}
+	}
+	if found == nil {
+		writeError(w, http.StatusNotFound, "Card not found")
+		return
+	}
+
+	resp := models.CardDetailResponse{
+		CardListItem: cardListItem(newCardLookups(store), *found),
+	}
+	skillMap := store.GetSkillMap()
+	if skill, ok := skillMap[found.SkillID]; ok {
+		resp.Skill = &skill
+	}
+	if skill, ok := skillMap[found.SpecialTrainingSkillID]; ok && found.SpecialTrainingSkillID > 0 {
+		resp.SpecialTrainingSkill = &skill
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
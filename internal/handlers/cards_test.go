@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"snowy_viewer/internal/models"
+)
+
+func TestCardListItems(t *testing.T) {
+	h := newTestHandler(t, map[string]string{
+		"events.json":     `[{"id": 1, "name": "Event"}]`,
+		"eventCards.json": `[{"id": 1, "cardId": 1, "eventId": 1}]`,
+		"cards.json": `[
+			{"id": 1, "characterId": 1, "cardRarityType": "rarity_4", "cardSupplyId": 2},
+			{"id": 2, "characterId": 2, "cardRarityType": "rarity_3", "cardSupplyId": 1},
+			{"id": 3, "characterId": 3, "cardRarityType": "rarity_2"}
+		]`,
+		"cardSupplies.json": `[
+			{"id": 1, "cardSupplyType": "normal"},
+			{"id": 2, "cardSupplyType": "term_limited"}
+		]`,
+		"gachas.json": `[{"id": 7, "name": "Gacha", "gachaPickups": [{"gachaId": 7, "cardId": 1}]}]`,
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/cards?server=tw&sortOrder=asc", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp models.CardListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Cards) != 3 {
+		t.Fatalf("got %d cards, want 3", len(resp.Cards))
+	}
+
+	first := resp.Cards[0]
+	if first.CardSupplyType != "term_limited" || first.Event == nil || first.Event.ID != 1 ||
+		len(first.Gachas) != 1 || first.Gachas[0].ID != 7 {
+		t.Errorf("card 1 = supply %q event %+v gachas %+v", first.CardSupplyType, first.Event, first.Gachas)
+	}
+	for _, c := range resp.Cards[1:] {
+		if c.CardSupplyType != "normal" || c.Event != nil || c.Gachas == nil || len(c.Gachas) != 0 {
+			t.Errorf("card %d = supply %q event %+v gachas %#v", c.ID, c.CardSupplyType, c.Event, c.Gachas)
+		}
+	}
+
+	// The supply type filter uses the same lookup
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/cards?server=tw&supplyTypes=normal", nil))
+	resp = models.CardListResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 2 {
+		t.Errorf("normal cards = %d, want 2", resp.Total)
+	}
+}
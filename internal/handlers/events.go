@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"snowy_viewer/internal/masterdata"
+	"snowy_viewer/internal/models"
+)
+
+// Event statuses relative to the server time
+const (
+	EventUpcoming    = "upcoming"
+	EventOngoing     = "ongoing"
+	EventAggregating = "aggregating"
+	EventEnded       = "ended"
+)
+
+// eventStatus returns the status of an event at now (unix milliseconds)
+func eventStatus(e models.Event, now int64) string {
+	switch {
+	case now < e.StartAt:
+		return EventUpcoming
+	case now < e.AggregateAt:
+		return EventOngoing
+	case now < e.ClosedAt:
+		return EventAggregating
+	default:
+		return EventEnded
+	}
+}
+
+func (h *Handler) handleEventList(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse Params
+	query := r.URL.Query()
+	paging := parsePagination(query, 20)
+	eventTypes := parseStringSet(query, "eventTypes")
+	statuses := parseStringSet(query, "statuses")
+	units := parseStringSet(query, "units")
+	search := strings.ToLower(strings.TrimSpace(query.Get("search")))
+	sortBy := query.Get("sortBy")
+	sortOrder := query.Get("sortOrder")
+
+	unitFilter := make(map[string]bool)
+	for unit := range units {
+		if alias, ok := unitAliases[unit]; ok {
+			unit = alias
+		}
+		unitFilter[unit] = true
+	}
+
+	now := time.Now().UnixMilli()
+
+	// Filter
+	searchId, searchIdErr := strconv.Atoi(search)
+	var filtered []models.EventListItem
+	for _, e := range store.GetEventList() {
+		if eventTypes != nil && !eventTypes[e.EventType] {
+			continue
+		}
+		if units != nil && !unitFilter[e.Unit] {
+			continue
+		}
+		status := eventStatus(e, now)
+		if statuses != nil && !statuses[status] {
+			continue
+		}
+		if search != "" && !(searchIdErr == nil && e.ID == searchId) &&
+			!strings.Contains(strings.ToLower(e.Name), search) {
+			continue
+		}
+		filtered = append(filtered, models.EventListItem{Event: e, Status: status})
+	}
+
+	// Sort
+	sort.SliceStable(filtered, func(i, j int) bool {
+		a, b := filtered[i], filtered[j]
+		var less bool
+		switch sortBy {
+		case "startAt":
+			less = a.StartAt < b.StartAt || (a.StartAt == b.StartAt && a.ID < b.ID)
+		default:
+			less = a.ID < b.ID
+		}
+		if sortOrder == "asc" {
+			return less
+		}
+		return !less
+	})
+
+	// Paginate
+	total := len(filtered)
+	start, end := paging.bounds(total)
+	paged := filtered[start:end]
+	if paged == nil {
+		paged = []models.EventListItem{}
+	}
+
+	writeJSON(w, http.StatusOK, models.EventListResponse{
+		Total:      total,
+		Page:       paging.page,
+		Limit:      paging.limit,
+		ServerTime: now,
+		Events:     paged,
+	})
+}
+
+func (h *Handler) handleEventDetail(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(parts) != 4 {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.Atoi(parts[3])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+
+	var found *models.Event
+	eventList := store.GetEventList()
+	for i := range eventList {
+		if eventList[i].ID == id {
+			found = &eventList[i]
+			break
+		}
+	}
+	if found == nil {
+		writeError(w, http.StatusNotFound, "Event not found")
+		return
+	}
+
+	now := time.Now().UnixMilli()
+	resp := models.EventDetailResponse{
+		EventListItem: models.EventListItem{Event: *found, Status: eventStatus(*found, now)},
+		ServerTime:    now,
+		CardIds:       store.GetEventCardIdsMap()[id],
+		MusicIds:      store.GetEventMusicIdsMap()[id],
+	}
+	if resp.CardIds == nil {
+		resp.CardIds = []int{}
+	}
+	if resp.MusicIds == nil {
+		resp.MusicIds = []int{}
+	}
+	if vl, ok := store.GetEventVirtualLiveMap()[id]; ok {
+		resp.VirtualLive = &vl
+	}
+	resp.Cards = eventCards(store, resp.CardIds)
+	resp.BonusCharacters, resp.BonusAttributes = eventBonuses(store, id)
+	resp.DeckBonuses = store.GetEventDeckBonusMap()[id]
+	if resp.DeckBonuses == nil {
+		resp.DeckBonuses = []models.EventDeckBonus{}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// eventCards resolves card ids to cards without their level parameters
+func eventCards(store *masterdata.Store, ids []int) []models.Card {
+	wanted := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	cards := []models.Card{}
+	for _, c := range store.GetCardList() {
+		if wanted[c.ID] {
+			c.CardParameters = nil
+			cards = append(cards, c)
+		}
+	}
+	return cards
+}
+
+// eventBonuses splits the deck bonuses of an event into the bonus
+// characters and the bonus attributes. Character entries keep their
+// highest bonus rate, which is the one combined with the attribute.
+func eventBonuses(store *masterdata.Store, eventId int) ([]models.EventBonusCharacter, []string) {
+	characterUnitMap := store.GetCharacterUnitMap()
+	characters := []models.EventBonusCharacter{}
+	attrs := []string{}
+	characterIndex := make(map[int]int)
+	seenAttrs := make(map[string]bool)
+
+	for _, b := range store.GetEventDeckBonusMap()[eventId] {
+		if b.CardAttr != "" && !seenAttrs[b.CardAttr] {
+			seenAttrs[b.CardAttr] = true
+			attrs = append(attrs, b.CardAttr)
+		}
+		if b.GameCharacterUnitID <= 0 {
+			continue
+		}
+		if i, ok := characterIndex[b.GameCharacterUnitID]; ok {
+			if b.BonusRate > characters[i].BonusRate {
+				characters[i].BonusRate = b.BonusRate
+			}
+			continue
+		}
+		cu := characterUnitMap[b.GameCharacterUnitID]
+		characterIndex[b.GameCharacterUnitID] = len(characters)
+		characters = append(characters, models.EventBonusCharacter{
+			GameCharacterUnitID: b.GameCharacterUnitID,
+			GameCharacterID:     cu.GameCharacterID,
+			Unit:                cu.Unit,
+			BonusRate:           b.BonusRate,
+		})
+	}
+	return characters, attrs
+}
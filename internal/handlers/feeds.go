@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"snowy_viewer/internal/bilibili"
+	"snowy_viewer/internal/feed"
+)
+
+// Feed formats by file extension
+var feedFormats = map[string]string{
+	".xml":  "rss",
+	".rss":  "rss",
+	".atom": "atom",
+	".json": "json",
+}
+
+// handleBilibiliFeed serves /feeds/bilibili/{uid}.xml as RSS 2.0. The
+// .atom and .json extensions, or ?format=atom|json, select Atom and JSON
+// Feed instead.
+func (h *Handler) handleBilibiliFeed(w http.ResponseWriter, r *http.Request) {
+	name := path.Base(r.URL.Path)
+	ext := path.Ext(name)
+	format, ok := feedFormats[ext]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if f := r.URL.Query().Get("format"); f != "" {
+		format = f
+	}
+	if format != "rss" && format != "atom" && format != "json" {
+		writeError(w, http.StatusBadRequest, "Unsupported format")
+		return
+	}
+	uid := strings.TrimSuffix(name, ext)
+	if !bilibili.ValidUID(uid) {
+		writeError(w, http.StatusBadRequest, "Invalid UID")
+		return
+	}
+
+	data, statusCode, err := h.bilibili.FetchDynamic(uid)
+	if err != nil {
+		writeError(w, statusCode, err.Error())
+		return
+	}
+	if statusCode != http.StatusOK {
+		writeError(w, statusCode, "Bilibili API Error")
+		return
+	}
+	dynamics, err := bilibili.ParseFeed(data)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	// Links built from request headers are only right for this client, so
+	// shared caches may keep the feed only with a configured base URL
+	baseURL, cacheControl := h.publicURL, "public, max-age=600"
+	if baseURL == "" {
+		baseURL, cacheControl = requestBaseURL(r), "private, max-age=600"
+	}
+	doc := bilibiliFeed(uid, dynamics.Items, baseURL, r.URL.Path)
+	var body []byte
+	var contentType string
+	switch format {
+	case "rss":
+		body, err = doc.RSS()
+		contentType = feed.ContentTypeRSS
+	case "atom":
+		doc.FeedURL = strings.TrimSuffix(doc.FeedURL, ext) + ".atom"
+		body, err = doc.Atom()
+		contentType = feed.ContentTypeAtom
+	default:
+		doc.FeedURL = strings.TrimSuffix(doc.FeedURL, ext) + ".json"
+		body, err = doc.JSON()
+		contentType = feed.ContentTypeJSON
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Write(body)
+}
+
+// bilibiliFeed maps a page of dynamics to a feed. Images are rewritten to
+// the image proxy under baseURL, since the Bilibili CDN blocks hotlinking.
+func bilibiliFeed(uid string, dynamics []bilibili.Dynamic, baseURL, feedPath string) *feed.Feed {
+	proxy := func(src string) string {
+		if src == "" {
+			return ""
+		}
+		return baseURL + "/api/bilibili/image?url=" + url.QueryEscape(src)
+	}
+
+	// Pinned posts lead the page; readers expect newest first
+	sort.SliceStable(dynamics, func(i, j int) bool {
+		return dynamics[i].PublishedAt > dynamics[j].PublishedAt
+	})
+
+	spaceURL := "https://space.bilibili.com/" + uid
+	doc := &feed.Feed{
+		ID:      spaceURL,
+		Title:   "Bilibili " + uid,
+		Link:    spaceURL + "/dynamic",
+		FeedURL: baseURL + feedPath,
+		Author:  feed.Author{URL: spaceURL},
+		Items:   make([]feed.Item, 0, len(dynamics)),
+	}
+	for _, d := range dynamics {
+		// Every post of the page is the account's own
+		if doc.Author.Name == "" {
+			doc.Author.Name = d.Author.Name
+			doc.Author.Avatar = proxy(d.Author.Face)
+		}
+		cover := d.CoverImage()
+		doc.Items = append(doc.Items, feed.Item{
+			ID:          "https://t.bilibili.com/" + d.ID,
+			Title:       d.Summary(),
+			Link:        d.URL,
+			ContentHTML: d.HTML(proxy),
+			ContentText: d.Text,
+			Image:       proxy(cover),
+			ImageType:   imageType(cover),
+			Published:   time.Unix(d.PublishedAt, 0),
+			Author:      feed.Author{Name: d.Author.Name, URL: spaceURL},
+		})
+	}
+	if doc.Author.Name != "" {
+		doc.Title = doc.Author.Name + " 的 Bilibili 动态"
+		doc.Description = doc.Title
+		doc.Icon = doc.Author.Avatar
+	}
+	return doc
+}
+
+// requestBaseURL returns the scheme and host the client used to reach us,
+// honouring the headers set by a reverse proxy. The client controls these
+// headers, so the result must not end up in shared caches.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" || proto == "http" {
+		scheme = proto
+	}
+	host := r.Host
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host, _, _ = strings.Cut(forwarded, ",")
+		host = strings.TrimSpace(host)
+	}
+	return scheme + "://" + host
+}
+
+// imageType guesses the MIME type of an image URL from its extension
+func imageType(imageURL string) string {
+	u, err := url.Parse(imageURL)
+	if err != nil {
+		return ""
+	}
+	contentType := mime.TypeByExtension(strings.ToLower(path.Ext(u.Path)))
+	if !strings.HasPrefix(contentType, "image/") {
+		return ""
+	}
+	return contentType
+}
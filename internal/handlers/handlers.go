@@ -8,64 +8,173 @@ import (
 	"strings"
 
 	"snowy_viewer/internal/bilibili"
+	"snowy_viewer/internal/cache"
 	"snowy_viewer/internal/masterdata"
 	"snowy_viewer/internal/models"
 )
 
 // Handler holds dependencies for HTTP handlers
 type Handler struct {
-	store    *masterdata.Store
+	stores   *masterdata.Registry
 	bilibili *bilibili.Client
+	cache    *cache.Cache
+	// publicURL is the external base URL of the server, if configured
+	publicURL string
 }
 
 // New creates a new Handler instance
-func New(store *masterdata.Store, biliClient *bilibili.Client) *Handler {
+func New(stores *masterdata.Registry, biliClient *bilibili.Client, appCache *cache.Cache) *Handler {
 	return &Handler{
-		store:    store,
+		stores:   stores,
 		bilibili: biliClient,
+		cache:    appCache,
 	}
 }
 
-// RegisterRoutes registers all API routes
+// SetPublicBaseURL sets the external scheme and host used in absolute
+// links, e.g. https://viewer.example.com
+func (h *Handler) SetPublicBaseURL(u string) {
+	h.publicURL = strings.TrimRight(u, "/")
+}
+
+// RegisterRoutes registers all API routes. Master data routes are also
+// reachable as /api/{server}/..., which is equivalent to ?server={server}.
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/api/card-event-map", h.handleCardEventMap)
-	mux.HandleFunc("/api/music-event-map", h.handleMusicEventMap)
-	mux.HandleFunc("/api/card-gacha-map", h.handleCardGachaMap)
-	mux.HandleFunc("/api/event-virtuallive-map", h.handleEventVirtualLiveMap)
-	mux.HandleFunc("/api/virtuallive-event-map", h.handleVirtualLiveEventMap)
-	mux.HandleFunc("/api/gachas", h.handleGachaList)
-	mux.HandleFunc("/api/gachas/", h.handleGachaDetail)
-	mux.HandleFunc("/api/cards/", h.handleCardCostumes)
-	mux.HandleFunc("/api/bilibili/dynamic/", h.handleBilibiliDynamic)
-	mux.HandleFunc("/api/bilibili/image", h.handleBilibiliImage)
+	api := http.NewServeMux()
+	api.HandleFunc("/api/card-event-map", h.handleCardEventMap)
+	api.HandleFunc("/api/music-event-map", h.handleMusicEventMap)
+	api.HandleFunc("/api/card-gacha-map", h.handleCardGachaMap)
+	api.HandleFunc("/api/event-virtuallive-map", h.handleEventVirtualLiveMap)
+	api.HandleFunc("/api/virtuallive-event-map", h.handleVirtualLiveEventMap)
+	api.HandleFunc("/api/gachas", h.handleGachaList)
+	api.HandleFunc("/api/gachas/", h.handleGachaDetail)
+	api.HandleFunc("/api/events", h.handleEventList)
+	api.HandleFunc("/api/events/", h.handleEventDetail)
+	api.HandleFunc("/api/musics", h.handleMusicList)
+	api.HandleFunc("/api/musics/", h.handleMusicDetail)
+	api.HandleFunc("/api/cards", h.handleCardList)
+	api.HandleFunc("/api/cards/", h.handleCardRoutes)
+	api.HandleFunc("/api/search", h.handleSearch)
+	api.HandleFunc("/api/masterdata/status", h.handleMasterDataStatus)
+	api.HandleFunc("/api/cache/status", h.handleCacheStatus)
+	api.HandleFunc("/api/bilibili/dynamic/", h.handleBilibiliDynamic)
+	api.HandleFunc("/api/bilibili/image", h.handleBilibiliImage)
+	api.HandleFunc("/api/bilibili/archive/", h.handleBilibiliArchive)
+	api.HandleFunc("/api/bilibili/timeline", h.handleBilibiliTimeline)
+	api.HandleFunc("/api/bilibili/live/", h.handleBilibiliLive)
+	api.HandleFunc("/api/bilibili/video/", h.handleBilibiliVideo)
+	api.HandleFunc("/api/bilibili/status", h.handleBilibiliStatus)
+
+	mux.Handle("/api/", api)
+	mux.HandleFunc("/feeds/bilibili/", h.handleBilibiliFeed)
+	for server := range masterdata.Sources {
+		mux.Handle("/api/"+server+"/", withServerPrefix(server, api))
+	}
+}
+
+// withServerPrefix rewrites /api/{server}/path to /api/path?server={server}
+func withServerPrefix(server string, next http.Handler) http.Handler {
+	prefix := "/api/" + server
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/api" + strings.TrimPrefix(r.URL.Path, prefix)
+		r2.URL.RawPath = ""
+		query := r2.URL.Query()
+		query.Set("server", server)
+		r2.URL.RawQuery = query.Encode()
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// storeFor resolves the master data store selected by the request.
+// It writes an error response and returns false for unknown servers.
+func (h *Handler) storeFor(w http.ResponseWriter, r *http.Request) (*masterdata.Store, bool) {
+	store, ok := h.stores.Get(r.URL.Query().Get("server"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Unknown server")
+		return nil, false
+	}
+	return store, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func (h *Handler) handleCacheStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.cache.Status())
+}
+
+func (h *Handler) handleMasterDataStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("server") == "" {
+		json.NewEncoder(w).Encode(h.stores.Statuses())
+		return
+	}
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(store.Status())
 }
 
 func (h *Handler) handleCardEventMap(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(h.store.GetCardEventMap())
+	json.NewEncoder(w).Encode(store.GetCardEventMap())
 }
 
 func (h *Handler) handleMusicEventMap(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(h.store.GetMusicEventMap())
+	json.NewEncoder(w).Encode(store.GetMusicEventMap())
 }
 
 func (h *Handler) handleCardGachaMap(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(h.store.GetCardGachaMap())
+	json.NewEncoder(w).Encode(store.GetCardGachaMap())
 }
 
 func (h *Handler) handleEventVirtualLiveMap(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(h.store.GetEventVirtualLiveMap())
+	json.NewEncoder(w).Encode(store.GetEventVirtualLiveMap())
 }
 
 func (h *Handler) handleVirtualLiveEventMap(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(h.store.GetVirtualLiveEventMap())
+	json.NewEncoder(w).Encode(store.GetVirtualLiveEventMap())
 }
 
 func (h *Handler) handleGachaList(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+
 	// Parse Params
 	query := r.URL.Query()
 	page, _ := strconv.Atoi(query.Get("page"))
@@ -80,8 +189,8 @@ func (h *Handler) handleGachaList(w http.ResponseWriter, r *http.Request) {
 	sortBy := query.Get("sortBy")
 	sortOrder := query.Get("sortOrder")
 
-	gachaList := h.store.GetGachaList()
-	gachaPickups := h.store.GetGachaPickups()
+	gachaList := store.GetGachaList()
+	gachaPickups := store.GetGachaPickups()
 
 	// Filter
 	var filtered []models.Gacha
@@ -165,8 +274,12 @@ func (h *Handler) handleGachaDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gachaList := h.store.GetGachaList()
-	gachaPickups := h.store.GetGachaPickups()
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+	gachaList := store.GetGachaList()
+	gachaPickups := store.GetGachaPickups()
 
 	var found *models.Gacha
 	for i := range gachaList {
@@ -210,11 +323,16 @@ func (h *Handler) handleCardCostumes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
-	cardCostume3dMap := h.store.GetCardCostume3dMap()
-	costume3dGroupIdMap := h.store.GetCostume3dGroupIdMap()
-	costume3dGroupMap := h.store.GetCostume3dGroupMap()
+	cardCostume3dMap := store.GetCardCostume3dMap()
+	costume3dGroupIdMap := store.GetCostume3dGroupIdMap()
+	costume3dGroupMap := store.GetCostume3dGroupMap()
 
 	costumeIds, ok := cardCostume3dMap[cardId]
 	if !ok || len(costumeIds) == 0 {
@@ -257,13 +375,38 @@ func (h *Handler) handleBilibiliDynamic(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Empty UID", http.StatusBadRequest)
 		return
 	}
+	if !bilibili.ValidUID(uid) {
+		http.Error(w, "Invalid UID", http.StatusBadRequest)
+		return
+	}
 
-	data, statusCode, err := h.bilibili.FetchDynamic(uid)
+	// Offsets are numeric cursors returned with the previous page
+	offset := r.URL.Query().Get("offset")
+	if offset != "" && !bilibili.ValidUID(offset) {
+		http.Error(w, "Invalid offset", http.StatusBadRequest)
+		return
+	}
+
+	data, statusCode, err := h.bilibili.FetchDynamicPage(uid, offset)
 	if err != nil {
 		http.Error(w, err.Error(), statusCode)
 		return
 	}
 
+	if r.URL.Query().Get("format") == "normalized" {
+		if statusCode != http.StatusOK {
+			writeError(w, statusCode, "Bilibili API Error")
+			return
+		}
+		feed, err := bilibili.ParseFeed(data)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, feed)
+		return
+	}
+
 	w.WriteHeader(statusCode)
 	w.Write(data)
 }
@@ -275,15 +418,21 @@ func (h *Handler) handleBilibiliImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, contentType, statusCode, err := h.bilibili.FetchImage(imageUrl)
+	transform, err := bilibili.ParseImageTransform(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, contentType, statusCode, err := h.bilibili.FetchImageVariant(imageUrl, transform)
 	if err != nil {
 		http.Error(w, err.Error(), statusCode)
 		return
 	}
 
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Cache-Control", "public, max-age=31536000")
 	if statusCode == http.StatusOK {
+		w.Header().Set("Cache-Control", "public, max-age=31536000")
 		w.Header().Set("X-Cache", "MISS") // Will be HIT on subsequent requests from cache
 	}
 	w.WriteHeader(statusCode)
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"snowy_viewer/internal/masterdata"
+	"snowy_viewer/internal/models"
+)
+
+// musicUnitTags maps unit identifiers, in card and short form, to music tags
+var musicUnitTags = map[string]string{
+	"light_sound":    "light_music_club",
+	"ln":             "light_music_club",
+	"idol":           "idol",
+	"mmj":            "idol",
+	"street":         "street",
+	"vbs":            "street",
+	"theme_park":     "theme_park",
+	"ws":             "theme_park",
+	"school_refusal": "school_refusal",
+	"25ji":           "school_refusal",
+	"piapro":         "vocaloid",
+	"vs":             "vocaloid",
+}
+
+// musicLookups holds the maps music list items are built from, read once
+// per request
+type musicLookups struct {
+	tags         map[int][]string
+	difficulties map[int][]models.MusicDifficulty
+	events       map[int][]models.EventInfo
+}
+
+func newMusicLookups(store *masterdata.Store) musicLookups {
+	return musicLookups{
+		tags:         store.GetMusicTagMap(),
+		difficulties: store.GetMusicDifficultyMap(),
+		events:       store.GetMusicEventMap(),
+	}
+}
+
+// musicListItem builds the list representation of a music
+func musicListItem(lookups musicLookups, m models.Music) models.MusicListItem {
+	item := models.MusicListItem{
+		Music:        m,
+		Tags:         lookups.tags[m.ID],
+		Difficulties: lookups.difficulties[m.ID],
+		Events:       lookups.events[m.ID],
+	}
+	if item.Tags == nil {
+		item.Tags = []string{}
+	}
+	if item.Difficulties == nil {
+		item.Difficulties = []models.MusicDifficulty{}
+	}
+	if item.Events == nil {
+		item.Events = []models.EventInfo{}
+	}
+	return item
+}
+
+func (h *Handler) handleMusicList(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+
+	// Parse Params
+	query := r.URL.Query()
+	paging := parsePagination(query, 30)
+	tags := parseStringSet(query, "tags")
+	units := parseStringSet(query, "units")
+	categories := parseStringSet(query, "categories")
+	difficulties := parseStringSet(query, "difficulties")
+	levelMin, hasLevelMin := parseInt64(query, "levelMin")
+	levelMax, hasLevelMax := parseInt64(query, "levelMax")
+	notesMin, hasNotesMin := parseInt64(query, "notesMin")
+	notesMax, hasNotesMax := parseInt64(query, "notesMax")
+	releaseFrom, hasReleaseFrom := parseInt64(query, "releaseFrom")
+	releaseTo, hasReleaseTo := parseInt64(query, "releaseTo")
+	search := strings.ToLower(strings.TrimSpace(query.Get("search")))
+	sortBy := query.Get("sortBy")
+	sortOrder := query.Get("sortOrder")
+
+	// Units are matched against music tags, separately from the tags filter
+	var unitTags map[string]bool
+	for unit := range units {
+		if tag, ok := musicUnitTags[unit]; ok {
+			unit = tag
+		}
+		if unitTags == nil {
+			unitTags = make(map[string]bool)
+		}
+		unitTags[unit] = true
+	}
+	chartFilter := difficulties != nil || hasLevelMin || hasLevelMax || hasNotesMin || hasNotesMax
+
+	lookups := newMusicLookups(store)
+
+	// Filter
+	searchId, searchIdErr := strconv.Atoi(search)
+	var filtered []models.Music
+	for _, m := range store.GetMusicList() {
+		if tags != nil && !hasAny(lookups.tags[m.ID], tags) {
+			continue
+		}
+		if unitTags != nil && !hasAny(lookups.tags[m.ID], unitTags) {
+			continue
+		}
+		if categories != nil && !hasAny(m.Categories, categories) {
+			continue
+		}
+		if hasReleaseFrom && m.PublishedAt < releaseFrom {
+			continue
+		}
+		if hasReleaseTo && m.PublishedAt > releaseTo {
+			continue
+		}
+		// A single chart has to satisfy every difficulty condition
+		if chartFilter {
+			matched := false
+			for _, d := range lookups.difficulties[m.ID] {
+				if difficulties != nil && !difficulties[d.MusicDifficulty] {
+					continue
+				}
+				if (hasLevelMin && int64(d.PlayLevel) < levelMin) || (hasLevelMax && int64(d.PlayLevel) > levelMax) {
+					continue
+				}
+				if (hasNotesMin && int64(d.TotalNoteCount) < notesMin) || (hasNotesMax && int64(d.TotalNoteCount) > notesMax) {
+					continue
+				}
+				matched = true
+				break
+			}
+			if !matched {
+				continue
+			}
+		}
+		if search != "" && !(searchIdErr == nil && m.ID == searchId) &&
+			!strings.Contains(strings.ToLower(m.Title), search) &&
+			!strings.Contains(strings.ToLower(m.Pronunciation), search) &&
+			!strings.Contains(strings.ToLower(m.Lyricist), search) &&
+			!strings.Contains(strings.ToLower(m.Composer), search) &&
+			!strings.Contains(strings.ToLower(m.Arranger), search) {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+
+	// Sort
+	sort.SliceStable(filtered, func(i, j int) bool {
+		a, b := filtered[i], filtered[j]
+		var less bool
+		switch sortBy {
+		case "publishedAt":
+			less = a.PublishedAt < b.PublishedAt || (a.PublishedAt == b.PublishedAt && a.ID < b.ID)
+		default:
+			less = a.ID < b.ID
+		}
+		if sortOrder == "asc" {
+			return less
+		}
+		return !less
+	})
+
+	// Paginate
+	total := len(filtered)
+	start, end := paging.bounds(total)
+	paged := filtered[start:end]
+
+	// Map to Response
+	resultItems := make([]models.MusicListItem, len(paged))
+	for i, m := range paged {
+		resultItems[i] = musicListItem(lookups, m)
+	}
+
+	writeJSON(w, http.StatusOK, models.MusicListResponse{
+		Total:  total,
+		Page:   paging.page,
+		Limit:  paging.limit,
+		Musics: resultItems,
+	})
+}
+
+func (h *Handler) handleMusicDetail(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	if len(parts) != 4 {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.Atoi(parts[3])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+
+	var found *models.Music
+	musicList := store.GetMusicList()
+	for i := range musicList {
+		if musicList[i].ID == id {
+			found = &musicList[i]
+			break
+		}
+	}
+	if found == nil {
+		writeError(w, http.StatusNotFound, "Music not found")
+		return
+	}
+
+	resp := models.MusicDetailResponse{
+		MusicListItem: musicListItem(newMusicLookups(store), *found),
+		Vocals:        store.GetMusicVocalMap()[id],
+	}
+	if resp.Vocals == nil {
+		resp.Vocals = []models.MusicVocal{}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// hasAny reports whether any of values is in set
+func hasAny(values []string, set map[string]bool) bool {
+	for _, v := range values {
+		if set[v] {
+			return true
+		}
+	}
+	return false
+}
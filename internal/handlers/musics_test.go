@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"snowy_viewer/internal/masterdata"
+	"snowy_viewer/internal/models"
+)
+
+// requiredMasterFiles are the files a store cannot load without
+var requiredMasterFiles = map[string]string{
+	"events.json":      `[{"id": 1, "name": "Event"}]`,
+	"eventCards.json":  `[{"id": 1, "cardId": 1, "eventId": 1}]`,
+	"eventMusics.json": `[{"eventId": 1, "musicId": 1}]`,
+}
+
+// newTestHandler serves the given master data files as the tw server and
+// returns the registered routes
+func newTestHandler(t *testing.T, files map[string]string) http.Handler {
+	t.Helper()
+	for name, body := range requiredMasterFiles {
+		if _, ok := files[name]; !ok {
+			files[name] = body
+		}
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"test"`)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(upstream.Close)
+
+	src := masterdata.Sources[masterdata.ServerTW]
+	t.Cleanup(func() { masterdata.Sources[masterdata.ServerTW] = src })
+	masterdata.Sources[masterdata.ServerTW] = masterdata.Source{Mirrors: []string{upstream.URL}}
+
+	stores := masterdata.NewRegistry([]string{masterdata.ServerTW}, t.TempDir()+"/master")
+	if errs := stores.Fetch(); errs[masterdata.ServerTW] != nil {
+		t.Fatal(errs[masterdata.ServerTW])
+	}
+	mux := http.NewServeMux()
+	New(stores, nil, nil).RegisterRoutes(mux)
+	return mux
+}
+
+func TestMusicListCombinesTagAndUnitFilters(t *testing.T) {
+	h := newTestHandler(t, map[string]string{
+		"musics.json": `[
+			{"id": 1, "title": "Leo/need and VIRTUAL SINGER"},
+			{"id": 2, "title": "Leo/need only"},
+			{"id": 3, "title": "VIRTUAL SINGER only"},
+			{"id": 4, "title": "MORE MORE JUMP! and VIRTUAL SINGER"}
+		]`,
+		"musicTags.json": `[
+			{"id": 1, "musicId": 1, "musicTag": "light_music_club"},
+			{"id": 2, "musicId": 1, "musicTag": "vocaloid"},
+			{"id": 3, "musicId": 2, "musicTag": "light_music_club"},
+			{"id": 4, "musicId": 3, "musicTag": "vocaloid"},
+			{"id": 5, "musicId": 4, "musicTag": "idol"},
+			{"id": 6, "musicId": 4, "musicTag": "vocaloid"}
+		]`,
+	})
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{1, 2, 3, 4}},
+		{"tags=vocaloid", []int{1, 3, 4}},
+		{"units=ln", []int{1, 2}},
+		{"units=ln,mmj", []int{1, 2, 4}},
+		// Both filters have to match
+		{"tags=vocaloid&units=ln", []int{1}},
+		{"tags=vocaloid&units=light_sound,idol", []int{1, 4}},
+		{"tags=idol&units=ln", nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/musics?server=tw&"+tt.query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.query, rec.Code, rec.Body)
+		}
+		var resp models.MusicListResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v", tt.query, err)
+		}
+		var got []int
+		for _, m := range resp.Musics {
+			got = append(got, m.ID)
+		}
+		sort.Ints(got)
+		if resp.Total != len(tt.want) || !equalInts(got, tt.want) {
+			t.Errorf("%s: got %v (total %d), want %v", tt.query, got, resp.Total, tt.want)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// pagination holds the page/limit query parameters
+type pagination struct {
+	page  int
+	limit int
+}
+
+func parsePagination(query url.Values, defaultLimit int) pagination {
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 {
+		limit = defaultLimit
+	}
+	return pagination{page: page, limit: limit}
+}
+
+// bounds returns the slice bounds of the current page within total items
+func (p pagination) bounds(total int) (int, int) {
+	start := (p.page - 1) * p.limit
+	if start > total {
+		start = total
+	}
+	end := start + p.limit
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+// parseIntSet parses a comma separated list of integers, ignoring invalid
+// entries. It returns nil when the parameter is absent.
+func parseIntSet(query url.Values, key string) map[int]bool {
+	raw := query.Get(key)
+	if raw == "" {
+		return nil
+	}
+	set := make(map[int]bool)
+	for _, item := range strings.Split(raw, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(item)); err == nil {
+			set[n] = true
+		}
+	}
+	return set
+}
+
+// parseStringSet parses a comma separated list of strings. It returns nil
+// when the parameter is absent.
+func parseStringSet(query url.Values, key string) map[string]bool {
+	raw := query.Get(key)
+	if raw == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			set[item] = true
+		}
+	}
+	return set
+}
+
+// parseInt64 parses an optional integer parameter
+func parseInt64(query url.Values, key string) (int64, bool) {
+	n, err := strconv.ParseInt(query.Get(key), 10, 64)
+	return n, err == nil
+}
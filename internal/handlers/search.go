@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"snowy_viewer/internal/models"
+)
+
+func (h *Handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	store, ok := h.storeFor(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "Missing q")
+		return
+	}
+	types := parseStringSet(query, "types")
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+
+	results := store.Search(q, types, 0)
+	total := len(results)
+	if total > limit {
+		results = results[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, models.SearchResponse{
+		Query:   q,
+		Total:   total,
+		Results: results,
+	})
+}
@@ -0,0 +1,250 @@
+package masterdata
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retry policy for remote fetches, configurable before the first Fetch
+var (
+	FetchRetries = 3
+	FetchBackoff = time.Second
+)
+
+var httpClient = &http.Client{Timeout: 120 * time.Second}
+
+// download is the result of a successful remote fetch
+type download struct {
+	url         string
+	header      http.Header
+	notModified bool
+	resumed     bool
+	attempts    int
+	size        int64
+}
+
+// errPermanent marks failures that retrying the same URL cannot fix
+type errPermanent struct{ err error }
+
+func (e errPermanent) Error() string { return e.err.Error() }
+
+// fetchRemote tries every mirror of filename in order, retrying each with
+// exponential backoff. On success target is decoded and the downloaded file
+// is staged in the mirror. cached enables a conditional request against the
+// URL it was fetched from; a 304 is reported via notModified and leaves
+// target untouched.
+func (s *Store) fetchRemote(filename string, cached *MirrorFile, target interface{}) (*download, error) {
+	var lastErr error
+	attempts := 0
+	for _, url := range s.source.URLs(filename) {
+		var partial *partialFile
+		for attempt := 0; attempt <= FetchRetries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(FetchBackoff << (attempt - 1))
+			}
+			attempts++
+
+			var conditional *MirrorFile
+			if cached != nil && cached.URL == url {
+				conditional = cached
+			}
+			dl, err := s.tryDownload(filename, url, conditional, &partial, target)
+			if err == nil {
+				dl.attempts = attempts
+				return dl, nil
+			}
+			lastErr = fmt.Errorf("%s: %v", url, err)
+			fmt.Printf("[%s] Warning: fetch %s attempt %d failed: %v\n", s.server, filename, attempt+1, lastErr)
+
+			var permanent errPermanent
+			if errors.As(err, &permanent) {
+				break
+			}
+		}
+		partial.remove()
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no source configured for %s", filename)
+	}
+	return nil, lastErr
+}
+
+// partialFile is an interrupted download that may be resumed
+type partialFile struct {
+	file      *os.File
+	size      int64
+	validator string
+}
+
+func (p *partialFile) remove() {
+	if p != nil {
+		p.file.Close()
+		os.Remove(p.file.Name())
+	}
+}
+
+// tryDownload performs one request. Interrupted transfers are kept in
+// *partial and resumed with a Range request on the next attempt when the
+// server supplied a validator for If-Range.
+func (s *Store) tryDownload(filename, url string, conditional *MirrorFile, partial **partialFile, target interface{}) (*download, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, errPermanent{err}
+	}
+	if conditional != nil {
+		if conditional.ETag != "" {
+			req.Header.Set("If-None-Match", conditional.ETag)
+		}
+		if conditional.LastModified != "" {
+			req.Header.Set("If-Modified-Since", conditional.LastModified)
+		}
+	}
+	p := *partial
+	if p != nil && p.size > 0 && p.validator != "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", p.size))
+		req.Header.Set("If-Range", p.validator)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// An unknown host will not resolve on the next attempt either
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, errPermanent{err}
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	resumed := false
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		p.remove()
+		*partial = nil
+		return &download{url: url, header: resp.Header, notModified: true}, nil
+	case http.StatusOK:
+		if p == nil {
+			tmp, err := s.mirror.tempFile(filename)
+			if err != nil {
+				return nil, errPermanent{err}
+			}
+			p = &partialFile{file: tmp}
+			*partial = p
+		} else if err := restart(p); err != nil {
+			return nil, err
+		}
+	case http.StatusPartialContent:
+		if p == nil || !strings.HasPrefix(resp.Header.Get("Content-Range"), "bytes "+strconv.FormatInt(p.size, 10)+"-") {
+			return nil, fmt.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		resumed = true
+	default:
+		err := fmt.Errorf("bad status: %s", resp.Status)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			return nil, errPermanent{err}
+		}
+		return nil, err
+	}
+
+	p.validator = resp.Header.Get("ETag")
+	if p.validator == "" || strings.HasPrefix(p.validator, "W/") {
+		p.validator = resp.Header.Get("Last-Modified")
+	}
+	n, err := io.Copy(p.file, resp.Body)
+	p.size += n
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := decodeStream(p.file, target)
+	if err != nil {
+		// The content itself is broken; start over rather than resume
+		restart(p)
+		p.validator = ""
+		return nil, err
+	}
+	if err := p.file.Sync(); err != nil {
+		return nil, errPermanent{err}
+	}
+	p.file.Close()
+	*partial = nil
+
+	s.mirror.stage(filename, p.file.Name(), MirrorFile{
+		SHA256:       sum,
+		Size:         p.size,
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+	})
+	return &download{url: url, header: resp.Header, resumed: resumed, size: p.size}, nil
+}
+
+// restart truncates a partial download
+func restart(p *partialFile) error {
+	p.size = 0
+	if err := p.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := p.file.Seek(0, io.SeekStart)
+	return err
+}
+
+// decodeStream decodes a file from its beginning without loading it into
+// memory and returns its SHA-256
+func decodeStream(f *os.File, target interface{}) (string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	reader := io.TeeReader(bufio.NewReaderSize(f, 64*1024), hash)
+	if err := json.NewDecoder(reader).Decode(target); err != nil {
+		return "", err
+	}
+	// Hash whatever the decoder did not consume
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// decodeFile streams a local JSON file into target and returns its size
+// and SHA-256
+func decodeFile(path string, target interface{}) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, "", err
+	}
+	sum, err := decodeStream(f, target)
+	return info.Size(), sum, err
+}
+
+// hashFile returns the SHA-256 of a file without reading it into memory
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
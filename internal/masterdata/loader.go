@@ -1,32 +1,36 @@
 package masterdata
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"snowy_viewer/internal/models"
 )
 
-const (
-	EventsURL         = "https://sekaimaster.exmeaning.com/master/events.json"
-	EventCardsURL     = "https://sekaimaster.exmeaning.com/master/eventCards.json"
-	EventMusicsURL    = "https://sekaimaster.exmeaning.com/master/eventMusics.json"
-	VirtualLivesURL   = "https://raw.githubusercontent.com/Team-Haruki/haruki-sekai-master/main/master/virtualLives.json"
-	GachasURL         = "https://raw.githubusercontent.com/Team-Haruki/haruki-sekai-master/main/master/gachas.json"
-	CardCostume3dsURL = "https://raw.githubusercontent.com/Team-Haruki/haruki-sekai-master/main/master/cardCostume3ds.json"
-	Costume3dsURL     = "https://raw.githubusercontent.com/Team-Haruki/haruki-sekai-master/main/master/costume3ds.json"
-)
-
-// Store holds all master data in memory
+// Store holds all master data of a single server in memory
 type Store struct {
 	mutex sync.RWMutex
 
+	// Event data
+	EventList         []models.Event
+	EventCardIdsMap   map[int][]int
+	EventMusicIdsMap  map[int][]int
+	EventDeckBonusMap map[int][]models.EventDeckBonus
+	CharacterUnitMap  map[int]models.GameCharacterUnit
+
+	// Music data
+	MusicList          []models.Music
+	MusicTagMap        map[int][]string
+	MusicDifficultyMap map[int][]models.MusicDifficulty
+	MusicVocalMap      map[int][]models.MusicVocal
+
+	// Search index over every named entity
+	searchIndex *searchIndex
+
 	// Card/Event/Music mappings
 	CardEventMap  map[int]models.EventInfo
 	MusicEventMap map[int][]models.EventInfo
@@ -45,13 +49,38 @@ type Store struct {
 	Costume3dGroupIdMap map[int]int
 	Costume3dGroupMap   map[int][]models.Costume3d
 
+	// Card data
+	CardList          []models.Card
+	CardSupplyTypeMap map[int]string
+	SkillMap          map[int]models.Skill
+
+	// Refresh state
+	fetchMutex  sync.Mutex
+	version     string
+	lastRefresh time.Time
+	lastCheck   time.Time
+	lastError   string
+	loading     []FileStatus
+	files       []FileStatus
+	failedFiles []FileStatus
+	previous    *snapshot
+	raw         rawData
+	mirror      *mirror
+	rejected    string
+	offline     bool
+
 	// Config
+	server        string
+	source        Source
 	localDataPath string
 }
 
-// NewStore creates a new master data store
-func NewStore(localDataPath string) *Store {
+// NewStore creates a new master data store for the given server
+func NewStore(server string, localDataPath string) *Store {
+	m := openMirror(localDataPath, server)
 	return &Store{
+		server:              server,
+		source:              Sources[server],
 		CardEventMap:        make(map[int]models.EventInfo),
 		MusicEventMap:       make(map[int][]models.EventInfo),
 		CardGachaMap:        make(map[int][]models.GachaInfo),
@@ -61,94 +90,231 @@ func NewStore(localDataPath string) *Store {
 		CardCostume3dMap:    make(map[int][]int),
 		Costume3dGroupIdMap: make(map[int]int),
 		Costume3dGroupMap:   make(map[int][]models.Costume3d),
+		EventCardIdsMap:     make(map[int][]int),
+		EventMusicIdsMap:    make(map[int][]int),
+		EventDeckBonusMap:   make(map[int][]models.EventDeckBonus),
+		CharacterUnitMap:    make(map[int]models.GameCharacterUnit),
+		MusicTagMap:         make(map[int][]string),
+		MusicDifficultyMap:  make(map[int][]models.MusicDifficulty),
+		MusicVocalMap:       make(map[int][]models.MusicVocal),
+		CardSupplyTypeMap:   make(map[int]string),
+		searchIndex:         newSearchIndex(),
+		SkillMap:            make(map[int]models.Skill),
 		localDataPath:       localDataPath,
+		mirror:              m,
+		rejected:            m.meta.Rejected,
 	}
 }
 
-func fetchJSON(url string, target interface{}) error {
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Get(url)
-	if err != nil {
-		return err
+// Server returns the server this store holds data for
+func (s *Store) Server() string {
+	return s.server
+}
+
+func (s *Store) loadOrFetch(filename string, target interface{}) (FileStatus, error) {
+	status := FileStatus{Name: filename, LoadedAt: time.Now()}
+
+	// Hand-placed local files take precedence; files we mirrored ourselves
+	// are revalidated against the remote and used when it is unavailable
+	localPath := filepath.Join(s.localDataPath, filename)
+	localSum, readErr := hashFile(localPath)
+	cached, mirrored := s.mirror.lookup(filename, localSum)
+	if readErr == nil && !mirrored {
+		if size, _, err := decodeFile(localPath, target); err == nil {
+			fmt.Printf("[%s] Loaded %s from local file\n", s.server, filename)
+			status.Source = FileSourceLocal
+			status.Location = localPath
+			status.Size = size
+			return status, nil
+		} else {
+			fmt.Printf("[%s] Warning: failed to unmarshal local %s: %v. Falling back to remote.\n", s.server, filename, err)
+			status.LocalError = err.Error()
+		}
+	} else if readErr != nil && !os.IsNotExist(readErr) {
+		fmt.Printf("[%s] Warning: failed to read local %s: %v. Falling back to remote.\n", s.server, filename, readErr)
+		status.LocalError = readErr.Error()
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", resp.Status)
+	// Data restored by a rollback is not revalidated against the version
+	// that was rolled back
+	if mirrored && s.offline {
+		if size, _, err := decodeFile(localPath, target); err == nil {
+			status.Source = FileSourceMirror
+			status.Location = localPath
+			status.Size = size
+			return status, nil
+		}
 	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
+
+	var conditional *MirrorFile
+	if mirrored {
+		conditional = &cached
+	}
+	status.Source = FileSourceRemote
+	status.Location = s.source.URL(filename)
+	dl, err := s.fetchRemote(filename, conditional, target)
+	if err == nil {
+		status.Location = dl.url
+		status.Attempts = dl.attempts
+		status.Resumed = dl.resumed
+		status.Size = dl.size
+		if !dl.notModified {
+			return status, nil
+		}
+		fmt.Printf("[%s] %s not modified, using local mirror\n", s.server, filename)
+		status.NotModified = true
+	} else {
+		status.RemoteError = err.Error()
 	}
-	return json.Unmarshal(body, target)
-}
 
-func (s *Store) loadOrFetch(filename string, url string, target interface{}) error {
-	localPath := filepath.Join(s.localDataPath, filename)
-	if _, err := os.Stat(localPath); err == nil {
-		content, err := os.ReadFile(localPath)
+	if mirrored {
+		if err != nil {
+			fmt.Printf("[%s] Warning: failed to fetch %s: %v. Using local mirror.\n", s.server, filename, err)
+		}
+		size, _, mirrorErr := decodeFile(localPath, target)
+		if mirrorErr == nil {
+			status.Source = FileSourceMirror
+			status.Location = localPath
+			status.Size = size
+			return status, nil
+		}
 		if err == nil {
-			if err := json.Unmarshal(content, target); err == nil {
-				fmt.Printf("Loaded %s from local file\n", filename)
-				return nil
-			} else {
-				fmt.Printf("Warning: failed to unmarshal local %s: %v. Falling back to remote.\n", filename, err)
-			}
-		} else {
-			fmt.Printf("Warning: failed to read local %s: %v. Falling back to remote.\n", filename, err)
+			err = mirrorErr
 		}
 	}
-	return fetchJSON(url, target)
+	return status, err
 }
 
-// Fetch loads all master data from local files or remote
+// Fetch loads all master data from local files or remote, regardless of
+// whether the version marker changed
 func (s *Store) Fetch() error {
-	fmt.Println("Updating master data...")
+	s.mutex.Lock()
+	s.rejected = ""
+	s.mutex.Unlock()
 
-	var events []models.Event
-	if err := s.loadOrFetch("events.json", EventsURL, &events); err != nil {
-		return fmt.Errorf("fetch events: %v", err)
+	version, err := s.currentVersion()
+	if err != nil {
+		fmt.Printf("[%s] Warning: %v\n", s.server, err)
 	}
+	return s.fetchVersion(version)
+}
 
-	var eventCards []models.EventCard
-	if err := s.loadOrFetch("eventCards.json", EventCardsURL, &eventCards); err != nil {
-		return fmt.Errorf("fetch eventCards: %v", err)
+// Load performs the initial load. While the upstream still serves the
+// version a rollback rejected before a restart, the rolled back data is
+// loaded from the mirror instead.
+func (s *Store) Load() error {
+	version, err := s.currentVersion()
+	if err != nil {
+		fmt.Printf("[%s] Warning: %v\n", s.server, err)
 	}
 
-	var eventMusics []models.EventMusic
-	if err := s.loadOrFetch("eventMusics.json", EventMusicsURL, &eventMusics); err != nil {
-		return fmt.Errorf("fetch eventMusics: %v", err)
+	s.fetchMutex.Lock()
+	defer s.fetchMutex.Unlock()
+	if version != "" && version == s.mirror.meta.Rejected {
+		fmt.Printf("[%s] Version %q was rolled back, loading the mirrored data\n", s.server, version)
+		s.offline = true
+		defer func() { s.offline = false }()
+		version = s.mirror.meta.Version
 	}
+	return s.fetchLocked(version)
+}
 
-	var virtualLives []models.VirtualLive
-	if err := s.loadOrFetch("virtualLives.json", VirtualLivesURL, &virtualLives); err != nil {
-		fmt.Printf("Warning: failed to fetch virtualLives: %v\n", err)
-	}
+// fetchVersion loads all master data and records it as the given version
+func (s *Store) fetchVersion(version string) error {
+	s.fetchMutex.Lock()
+	defer s.fetchMutex.Unlock()
+	return s.fetchLocked(version)
+}
 
-	var gachas []models.Gacha
-	if err := s.loadOrFetch("gachas.json", GachasURL, &gachas); err != nil {
-		fmt.Printf("Warning: failed to fetch gachas: %v\n", err)
+// fetchLocked is fetchVersion with s.fetchMutex held
+func (s *Store) fetchLocked(version string) error {
+	s.loading = nil
+	s.mirror.begin()
+	err := s.load(version)
+	if err != nil {
+		s.mirror.finish(false)
 	}
 
-	var cardCostume3ds []models.CardCostume3d
-	if err := s.loadOrFetch("cardCostume3ds.json", CardCostume3dsURL, &cardCostume3ds); err != nil {
-		fmt.Printf("Warning: failed to fetch cardCostume3ds: %v\n", err)
+	s.mutex.Lock()
+	s.lastCheck = time.Now()
+	if err != nil {
+		s.lastError = err.Error()
+		s.failedFiles = s.loading
+	} else {
+		s.lastError = ""
+		s.failedFiles = nil
 	}
+	s.mutex.Unlock()
+	return err
+}
+
+func (s *Store) load(version string) error {
+	fmt.Printf("[%s] Updating master data...\n", s.server)
 
-	var costume3ds []models.Costume3d
-	if err := s.loadOrFetch("costume3ds.json", Costume3dsURL, &costume3ds); err != nil {
-		fmt.Printf("Warning: failed to fetch costume3ds: %v\n", err)
+	// Every file falls back to its previous good version when the new
+	// contents fail to load or validate
+	previous := s.raw
+	var next rawData
+	var err error
+
+	if next.events, err = loadFile(s, "events.json", previous.events, validateEvents); err != nil {
+		return fmt.Errorf("fetch events: %v", err)
+	}
+	if next.eventCards, err = loadFile(s, "eventCards.json", previous.eventCards, validateEventCards(next.events)); err != nil {
+		return fmt.Errorf("fetch eventCards: %v", err)
+	}
+	if next.eventMusics, err = loadFile(s, "eventMusics.json", previous.eventMusics, validateEventMusics(next.events)); err != nil {
+		return fmt.Errorf("fetch eventMusics: %v", err)
+	}
+	if next.virtualLives, err = loadFile(s, "virtualLives.json", previous.virtualLives, validateVirtualLives(next.events)); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch virtualLives: %v\n", s.server, err)
+	}
+	if next.gachas, err = loadFile(s, "gachas.json", previous.gachas, validateGachas); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch gachas: %v\n", s.server, err)
+	}
+	if next.costume3ds, err = loadFile(s, "costume3ds.json", previous.costume3ds, validateCostume3ds); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch costume3ds: %v\n", s.server, err)
+	}
+	if next.cardCostume3ds, err = loadFile(s, "cardCostume3ds.json", previous.cardCostume3ds, validateCardCostume3ds(next.costume3ds)); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch cardCostume3ds: %v\n", s.server, err)
+	}
+	if next.cards, err = loadFile(s, "cards.json", previous.cards, validateCards); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch cards: %v\n", s.server, err)
+	}
+	if next.cardSupplies, err = loadFile(s, "cardSupplies.json", previous.cardSupplies, validateCardSupplies); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch cardSupplies: %v\n", s.server, err)
+	}
+	if next.skills, err = loadFile(s, "skills.json", previous.skills, validateSkills); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch skills: %v\n", s.server, err)
+	}
+	if next.deckBonuses, err = loadFile(s, "eventDeckBonuses.json", previous.deckBonuses, validateEventDeckBonuses(next.events)); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch eventDeckBonuses: %v\n", s.server, err)
+	}
+	if next.characterUnits, err = loadFile(s, "gameCharacterUnits.json", previous.characterUnits, validateGameCharacterUnits); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch gameCharacterUnits: %v\n", s.server, err)
+	}
+	if next.musics, err = loadFile(s, "musics.json", previous.musics, validateMusics); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch musics: %v\n", s.server, err)
+	}
+	if next.musicTags, err = loadFile(s, "musicTags.json", previous.musicTags, validateMusicTags(next.musics)); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch musicTags: %v\n", s.server, err)
+	}
+	if next.difficulties, err = loadFile(s, "musicDifficulties.json", previous.difficulties, validateMusicDifficulties(next.musics)); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch musicDifficulties: %v\n", s.server, err)
+	}
+	if next.vocals, err = loadFile(s, "musicVocals.json", previous.vocals, validateMusicVocals(next.musics)); err != nil {
+		fmt.Printf("[%s] Warning: failed to fetch musicVocals: %v\n", s.server, err)
 	}
 
 	// Build Maps
 	newCardEventMap := make(map[int]models.EventInfo)
 	newMusicEventMap := make(map[int][]models.EventInfo)
 	eventLookup := make(map[int]models.Event)
-	for _, e := range events {
+	for _, e := range next.events {
 		eventLookup[e.ID] = e
 	}
 
-	for _, ec := range eventCards {
+	for _, ec := range next.eventCards {
 		if ev, ok := eventLookup[ec.EventID]; ok {
 			if existing, exists := newCardEventMap[ec.CardID]; exists {
 				if ev.ID < existing.ID {
@@ -168,7 +334,7 @@ func (s *Store) Fetch() error {
 		}
 	}
 
-	for _, em := range eventMusics {
+	for _, em := range next.eventMusics {
 		if ev, ok := eventLookup[em.EventID]; ok {
 			info := models.EventInfo{
 				ID:              ev.ID,
@@ -181,13 +347,13 @@ func (s *Store) Fetch() error {
 
 	// Build Virtual Live <-> Event mappings
 	virtualLiveLookup := make(map[int]models.VirtualLive)
-	for _, vl := range virtualLives {
+	for _, vl := range next.virtualLives {
 		virtualLiveLookup[vl.ID] = vl
 	}
 
 	newEventVirtualLiveMap := make(map[int]models.VirtualLiveInfo)
 	newVirtualLiveEventMap := make(map[int]models.EventInfo)
-	for _, e := range events {
+	for _, e := range next.events {
 		if e.VirtualLiveId > 0 {
 			if vl, ok := virtualLiveLookup[e.VirtualLiveId]; ok {
 				newEventVirtualLiveMap[e.ID] = models.VirtualLiveInfo{
@@ -205,14 +371,14 @@ func (s *Store) Fetch() error {
 	}
 
 	newGachaPickups := make(map[int][]int)
-	for _, g := range gachas {
+	for _, g := range next.gachas {
 		for _, p := range g.GachaPickups {
 			newGachaPickups[g.ID] = append(newGachaPickups[g.ID], p.CardID)
 		}
 	}
 
 	newCardGachaMap := make(map[int][]models.GachaInfo)
-	for _, g := range gachas {
+	for _, g := range next.gachas {
 		info := models.GachaInfo{
 			ID:              g.ID,
 			Name:            g.Name,
@@ -228,41 +394,140 @@ func (s *Store) Fetch() error {
 	newCostume3dGroupIdMap := make(map[int]int)
 	newCostume3dGroupMap := make(map[int][]models.Costume3d)
 
-	for _, cc := range cardCostume3ds {
+	for _, cc := range next.cardCostume3ds {
 		newCardCostume3dMap[cc.CardID] = append(newCardCostume3dMap[cc.CardID], cc.Costume3dID)
 	}
 
-	for _, c := range costume3ds {
+	for _, c := range next.costume3ds {
 		newCostume3dGroupIdMap[c.ID] = c.Costume3dGroupId
 		newCostume3dGroupMap[c.Costume3dGroupId] = append(newCostume3dGroupMap[c.Costume3dGroupId], c)
 	}
 
-	// Update store atomically
+	// Build Event Maps
+	newEventCardIdsMap := make(map[int][]int)
+	for _, ec := range next.eventCards {
+		newEventCardIdsMap[ec.EventID] = append(newEventCardIdsMap[ec.EventID], ec.CardID)
+	}
+
+	sortedEventMusics := make([]models.EventMusic, len(next.eventMusics))
+	copy(sortedEventMusics, next.eventMusics)
+	sort.SliceStable(sortedEventMusics, func(i, j int) bool {
+		return sortedEventMusics[i].Seq < sortedEventMusics[j].Seq
+	})
+	newEventMusicIdsMap := make(map[int][]int)
+	for _, em := range sortedEventMusics {
+		newEventMusicIdsMap[em.EventID] = append(newEventMusicIdsMap[em.EventID], em.MusicID)
+	}
+
+	newCharacterUnitMap := make(map[int]models.GameCharacterUnit)
+	for _, cu := range next.characterUnits {
+		newCharacterUnitMap[cu.ID] = cu
+	}
+
+	newEventDeckBonusMap := make(map[int][]models.EventDeckBonus)
+	for _, b := range next.deckBonuses {
+		newEventDeckBonusMap[b.EventID] = append(newEventDeckBonusMap[b.EventID], b)
+	}
+
+	// Build Music Maps
+	newMusicTagMap := make(map[int][]string)
+	sortedMusicTags := make([]models.MusicTag, len(next.musicTags))
+	copy(sortedMusicTags, next.musicTags)
+	sort.SliceStable(sortedMusicTags, func(i, j int) bool {
+		return sortedMusicTags[i].Seq < sortedMusicTags[j].Seq
+	})
+	for _, t := range sortedMusicTags {
+		newMusicTagMap[t.MusicID] = append(newMusicTagMap[t.MusicID], t.MusicTag)
+	}
+
+	newMusicDifficultyMap := make(map[int][]models.MusicDifficulty)
+	for _, d := range next.difficulties {
+		newMusicDifficultyMap[d.MusicID] = append(newMusicDifficultyMap[d.MusicID], d)
+	}
+	for _, difficulties := range newMusicDifficultyMap {
+		sort.SliceStable(difficulties, func(i, j int) bool {
+			return difficulties[i].ID < difficulties[j].ID
+		})
+	}
+
+	newMusicVocalMap := make(map[int][]models.MusicVocal)
+	for _, v := range next.vocals {
+		newMusicVocalMap[v.MusicID] = append(newMusicVocalMap[v.MusicID], v)
+	}
+	for _, vocals := range newMusicVocalMap {
+		sort.SliceStable(vocals, func(i, j int) bool {
+			return vocals[i].Seq < vocals[j].Seq
+		})
+	}
+
+	newSearchIndex := buildSearchIndex(next)
+
+	// Build Card Maps
+	newCardSupplyTypeMap := make(map[int]string)
+	for _, cs := range next.cardSupplies {
+		newCardSupplyTypeMap[cs.ID] = cs.CardSupplyType
+	}
+
+	newSkillMap := make(map[int]models.Skill)
+	for _, sk := range next.skills {
+		newSkillMap[sk.ID] = sk
+	}
+
+	// Update store atomically, keeping the current data for rollback
 	s.mutex.Lock()
+	if !s.lastRefresh.IsZero() {
+		previous := s.capture()
+		s.previous = &previous
+	}
+	s.mirror.finish(s.previous != nil)
+	s.version = version
+	s.raw = next
+	if err := s.mirror.setVersion(version, s.rejected); err != nil {
+		fmt.Printf("[%s] Warning: failed to update mirror metadata: %v\n", s.server, err)
+	}
+	s.files = s.loading
+	s.lastRefresh = time.Now()
+	s.EventList = next.events
+	s.EventCardIdsMap = newEventCardIdsMap
+	s.EventMusicIdsMap = newEventMusicIdsMap
+	s.EventDeckBonusMap = newEventDeckBonusMap
+	s.CharacterUnitMap = newCharacterUnitMap
+	s.MusicList = next.musics
+	s.MusicTagMap = newMusicTagMap
+	s.MusicDifficultyMap = newMusicDifficultyMap
+	s.MusicVocalMap = newMusicVocalMap
+	s.searchIndex = newSearchIndex
 	s.CardEventMap = newCardEventMap
 	s.MusicEventMap = newMusicEventMap
 	s.CardGachaMap = newCardGachaMap
 	s.EventVirtualLiveMap = newEventVirtualLiveMap
 	s.VirtualLiveEventMap = newVirtualLiveEventMap
-	s.GachaList = gachas
+	s.GachaList = next.gachas
 	s.GachaPickups = newGachaPickups
 	s.CardCostume3dMap = newCardCostume3dMap
 	s.Costume3dGroupIdMap = newCostume3dGroupIdMap
 	s.Costume3dGroupMap = newCostume3dGroupMap
+	s.CardList = next.cards
+	s.CardSupplyTypeMap = newCardSupplyTypeMap
+	s.SkillMap = newSkillMap
 	s.mutex.Unlock()
 
-	fmt.Printf("Data updated. Mapped %d cards, %d musics, %d event-vl, loaded %d gachas, %d costumes.\n",
-		len(newCardEventMap), len(newMusicEventMap), len(newEventVirtualLiveMap), len(gachas), len(costume3ds))
+	fmt.Printf("[%s] Data updated. Mapped %d cards, %d musics, %d event-vl, loaded %d events, %d gachas, %d costumes, %d card details, %d music details.\n",
+		s.server, len(newCardEventMap), len(newMusicEventMap), len(newEventVirtualLiveMap), len(next.events), len(next.gachas), len(next.costume3ds), len(next.cards), len(next.musics))
 	return nil
 }
 
-// StartPeriodicUpdate starts a goroutine to update data periodically
+// StartPeriodicUpdate starts a goroutine that checks the version marker
+// periodically and reloads the data when it changed
 func (s *Store) StartPeriodicUpdate(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	go func() {
 		for range ticker.C {
-			if err := s.Fetch(); err != nil {
-				fmt.Printf("Periodic update error: %v\n", err)
+			updated, err := s.Refresh()
+			if err != nil {
+				fmt.Printf("[%s] Periodic update error: %v\n", s.server, err)
+			} else if !updated {
+				fmt.Printf("[%s] Master data unchanged\n", s.server)
 			}
 		}
 	}()
@@ -270,6 +535,60 @@ func (s *Store) StartPeriodicUpdate(interval time.Duration) {
 
 // Thread-safe getters
 
+func (s *Store) GetMusicList() []models.Music {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.MusicList
+}
+
+func (s *Store) GetMusicTagMap() map[int][]string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.MusicTagMap
+}
+
+func (s *Store) GetMusicDifficultyMap() map[int][]models.MusicDifficulty {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.MusicDifficultyMap
+}
+
+func (s *Store) GetMusicVocalMap() map[int][]models.MusicVocal {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.MusicVocalMap
+}
+
+func (s *Store) GetEventList() []models.Event {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.EventList
+}
+
+func (s *Store) GetEventCardIdsMap() map[int][]int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.EventCardIdsMap
+}
+
+func (s *Store) GetEventMusicIdsMap() map[int][]int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.EventMusicIdsMap
+}
+
+func (s *Store) GetEventDeckBonusMap() map[int][]models.EventDeckBonus {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.EventDeckBonusMap
+}
+
+func (s *Store) GetCharacterUnitMap() map[int]models.GameCharacterUnit {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.CharacterUnitMap
+}
+
 func (s *Store) GetCardEventMap() map[int]models.EventInfo {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -329,3 +648,21 @@ func (s *Store) GetCostume3dGroupMap() map[int][]models.Costume3d {
 	defer s.mutex.RUnlock()
 	return s.Costume3dGroupMap
 }
+
+func (s *Store) GetCardList() []models.Card {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.CardList
+}
+
+func (s *Store) GetCardSupplyTypeMap() map[int]string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.CardSupplyTypeMap
+}
+
+func (s *Store) GetSkillMap() map[int]models.Skill {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.SkillMap
+}
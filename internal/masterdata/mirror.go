@@ -0,0 +1,245 @@
+package masterdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"snowy_viewer/internal/fsutil"
+)
+
+// mirrorMetaFile is written next to the mirrored JSON files
+const mirrorMetaFile = "mirror.json"
+
+// Directories holding the generation replaced by the last load, and the
+// current generation while a load or rollback is in progress
+const (
+	previousGenDir = ".previous"
+	stagingGenDir  = ".previous.tmp"
+)
+
+// MirrorFile describes a remote file persisted to the local mirror
+type MirrorFile struct {
+	SHA256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+type mirrorMeta struct {
+	Server    string                `json:"server"`
+	Version   string                `json:"version"`
+	Rejected  string                `json:"rejected,omitempty"`
+	UpdatedAt time.Time             `json:"updatedAt"`
+	Files     map[string]MirrorFile `json:"files"`
+}
+
+// pendingFile is a downloaded file waiting for validation
+type pendingFile struct {
+	tempPath string
+	info     MirrorFile
+}
+
+// mirror persists validated remote files into the local data directory so
+// the server can boot offline. Files listed in mirror.json with a matching
+// hash are treated as a cache of remote data; any other local file is
+// considered hand-placed and keeps precedence over remote data.
+// All methods are called with Store.fetchMutex held.
+type mirror struct {
+	dir     string
+	meta    mirrorMeta
+	pending map[string]pendingFile
+	staged  bool
+}
+
+func openMirror(dir, server string) *mirror {
+	m := &mirror{
+		dir: dir,
+		meta: mirrorMeta{
+			Server: server,
+			Files:  make(map[string]MirrorFile),
+		},
+		pending: make(map[string]pendingFile),
+	}
+	content, err := os.ReadFile(filepath.Join(dir, mirrorMetaFile))
+	if err != nil {
+		return m
+	}
+	if err := json.Unmarshal(content, &m.meta); err != nil {
+		fmt.Printf("[%s] Warning: ignoring corrupt %s: %v\n", server, mirrorMetaFile, err)
+		m.meta.Files = make(map[string]MirrorFile)
+	}
+	if m.meta.Files == nil {
+		m.meta.Files = make(map[string]MirrorFile)
+	}
+	return m
+}
+
+// lookup returns the mirror entry of filename if sum matches it
+func (m *mirror) lookup(filename, sum string) (MirrorFile, bool) {
+	info, ok := m.meta.Files[filename]
+	return info, ok && info.SHA256 == sum
+}
+
+// tempFile creates a temporary file for a download of filename. It falls
+// back to the system temp directory when the mirror is not writable, in
+// which case the later commit fails and the download is not mirrored.
+func (m *mirror) tempFile(filename string) (*os.File, error) {
+	if err := os.MkdirAll(m.dir, 0o755); err == nil {
+		if f, err := os.CreateTemp(m.dir, "."+filename+".*.tmp"); err == nil {
+			return f, nil
+		}
+	}
+	return os.CreateTemp("", filename+".*.tmp")
+}
+
+// stage records a completed download awaiting validation
+func (m *mirror) stage(filename, tempPath string, info MirrorFile) {
+	m.discard(filename)
+	m.pending[filename] = pendingFile{tempPath: tempPath, info: info}
+}
+
+// commit atomically moves a staged download into place. It reports
+// whether a staged file existed.
+func (m *mirror) commit(filename string) (bool, error) {
+	p, ok := m.pending[filename]
+	if !ok {
+		return false, nil
+	}
+	delete(m.pending, filename)
+
+	if err := os.Rename(p.tempPath, filepath.Join(m.dir, filename)); err != nil {
+		os.Remove(p.tempPath)
+		return false, err
+	}
+	m.meta.Files[filename] = p.info
+	return true, m.save()
+}
+
+// discard drops a staged download
+func (m *mirror) discard(filename string) {
+	if p, ok := m.pending[filename]; ok {
+		os.Remove(p.tempPath)
+		delete(m.pending, filename)
+	}
+}
+
+// setVersion records the version of the data set the mirror belongs to
+// and the version rejected by a rollback, if any
+func (m *mirror) setVersion(version, rejected string) error {
+	if len(m.meta.Files) == 0 || (m.meta.Version == version && m.meta.Rejected == rejected) {
+		return nil
+	}
+	m.meta.Version = version
+	m.meta.Rejected = rejected
+	return m.save()
+}
+
+// begin preserves the current generation before a load commits files over
+// it. The hard links keep the old contents when the files are replaced.
+func (m *mirror) begin() {
+	m.staged = false
+	staging := filepath.Join(m.dir, stagingGenDir)
+	os.RemoveAll(staging)
+	if len(m.meta.Files) == 0 {
+		return
+	}
+	if err := m.link(staging); err != nil {
+		fmt.Printf("[%s] Warning: failed to preserve mirror generation: %v\n", m.meta.Server, err)
+		os.RemoveAll(staging)
+		return
+	}
+	m.staged = true
+}
+
+// finish ends a load. When keep is set the generation preserved by begin
+// becomes the previous generation, otherwise it is dropped.
+func (m *mirror) finish(keep bool) {
+	staging := filepath.Join(m.dir, stagingGenDir)
+	if !keep {
+		os.RemoveAll(staging)
+		return
+	}
+	previous := filepath.Join(m.dir, previousGenDir)
+	os.RemoveAll(previous)
+	if m.staged {
+		if err := os.Rename(staging, previous); err != nil {
+			fmt.Printf("[%s] Warning: failed to keep previous mirror generation: %v\n", m.meta.Server, err)
+			os.RemoveAll(staging)
+		}
+	}
+}
+
+// rollback swaps the current and the previous generation and records the
+// version rolled back from, so a restart does not load it again
+func (m *mirror) rollback(rejected string) error {
+	previous := filepath.Join(m.dir, previousGenDir)
+	content, err := os.ReadFile(filepath.Join(previous, mirrorMetaFile))
+	if err != nil {
+		return fmt.Errorf("no previous mirror generation: %v", err)
+	}
+	var meta mirrorMeta
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return fmt.Errorf("previous mirror generation: %v", err)
+	}
+	if meta.Files == nil {
+		meta.Files = make(map[string]MirrorFile)
+	}
+
+	staging := filepath.Join(m.dir, stagingGenDir)
+	os.RemoveAll(staging)
+	if err := m.link(staging); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	for filename := range meta.Files {
+		if err := os.Rename(filepath.Join(previous, filename), filepath.Join(m.dir, filename)); err != nil {
+			return err
+		}
+	}
+	for filename := range m.meta.Files {
+		if _, ok := meta.Files[filename]; !ok {
+			os.Remove(filepath.Join(m.dir, filename))
+		}
+	}
+	m.meta.Version = meta.Version
+	m.meta.Files = meta.Files
+	m.meta.Rejected = rejected
+	if err := m.save(); err != nil {
+		return err
+	}
+
+	os.RemoveAll(previous)
+	return os.Rename(staging, previous)
+}
+
+// link hard-links the files of the current generation and writes its
+// metadata into dir
+func (m *mirror) link(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for filename := range m.meta.Files {
+		if err := os.Link(filepath.Join(m.dir, filename), filepath.Join(dir, filename)); err != nil {
+			return err
+		}
+	}
+	content, err := json.MarshalIndent(m.meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(filepath.Join(dir, mirrorMetaFile), content, 0o644)
+}
+
+func (m *mirror) save() error {
+	m.meta.UpdatedAt = time.Now()
+	content, err := json.MarshalIndent(m.meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(filepath.Join(m.dir, mirrorMetaFile), content, 0o644)
+}
@@ -0,0 +1,121 @@
+package masterdata
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry holds one independently loaded Store per server
+type Registry struct {
+	stores        map[string]*Store
+	servers       []string
+	defaultServer string
+}
+
+// NewRegistry creates a store for every requested server. The default
+// server reads local files from basePath, the others from basePath-{server}.
+func NewRegistry(servers []string, basePath string) *Registry {
+	r := &Registry{
+		stores: make(map[string]*Store),
+	}
+	for _, server := range servers {
+		server = strings.ToLower(strings.TrimSpace(server))
+		if !IsValidServer(server) {
+			fmt.Printf("Warning: unknown master data server %q ignored\n", server)
+			continue
+		}
+		if _, exists := r.stores[server]; exists {
+			continue
+		}
+		r.stores[server] = NewStore(server, LocalDataPath(basePath, server))
+		r.servers = append(r.servers, server)
+	}
+
+	if _, ok := r.stores[DefaultServer]; ok {
+		r.defaultServer = DefaultServer
+	} else if len(r.servers) > 0 {
+		r.defaultServer = r.servers[0]
+	} else {
+		// Always serve something, even with an empty server list
+		r.stores[DefaultServer] = NewStore(DefaultServer, basePath)
+		r.servers = []string{DefaultServer}
+		r.defaultServer = DefaultServer
+	}
+	return r
+}
+
+// LocalDataPath returns the local master data directory for a server
+func LocalDataPath(basePath, server string) string {
+	if server == DefaultServer {
+		return basePath
+	}
+	return basePath + "-" + server
+}
+
+// Get returns the store for a server. An empty server selects the default.
+func (r *Registry) Get(server string) (*Store, bool) {
+	if server == "" {
+		return r.stores[r.defaultServer], true
+	}
+	s, ok := r.stores[strings.ToLower(server)]
+	return s, ok
+}
+
+// Default returns the store of the default server
+func (r *Registry) Default() *Store {
+	return r.stores[r.defaultServer]
+}
+
+// Servers returns the enabled servers in configuration order
+func (r *Registry) Servers() []string {
+	return r.servers
+}
+
+// Fetch performs the initial load of every store concurrently and returns
+// the errors by server
+func (r *Registry) Fetch() map[string]error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := make(map[string]error)
+
+	for server, store := range r.stores {
+		wg.Add(1)
+		go func(server string, store *Store) {
+			defer wg.Done()
+			if err := store.Load(); err != nil {
+				mu.Lock()
+				errs[server] = err
+				mu.Unlock()
+			}
+		}(server, store)
+	}
+	wg.Wait()
+	return errs
+}
+
+// StartPeriodicUpdate starts the periodic refresh of every store
+func (r *Registry) StartPeriodicUpdate(interval time.Duration) {
+	for _, server := range r.servers {
+		r.stores[server].StartPeriodicUpdate(interval)
+	}
+}
+
+// Statuses returns the refresh status of every store
+func (r *Registry) Statuses() []Status {
+	statuses := make([]Status, 0, len(r.servers))
+	for _, server := range r.servers {
+		statuses = append(statuses, r.stores[server].Status())
+	}
+	return statuses
+}
+
+// DetailedStatuses returns the detailed status of every store
+func (r *Registry) DetailedStatuses() []DetailedStatus {
+	statuses := make([]DetailedStatus, 0, len(r.servers))
+	for _, server := range r.servers {
+		statuses = append(statuses, r.stores[server].DetailedStatus())
+	}
+	return statuses
+}
@@ -0,0 +1,285 @@
+package masterdata
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"snowy_viewer/internal/models"
+)
+
+// Searchable entity types, in the order they are ranked on equal scores
+const (
+	SearchTypeCard        = "card"
+	SearchTypeEvent       = "event"
+	SearchTypeGacha       = "gacha"
+	SearchTypeMusic       = "music"
+	SearchTypeCostume     = "costume"
+	SearchTypeVirtualLive = "virtualLive"
+)
+
+var searchTypeOrder = map[string]int{
+	SearchTypeCard:        0,
+	SearchTypeEvent:       1,
+	SearchTypeMusic:       2,
+	SearchTypeGacha:       3,
+	SearchTypeVirtualLive: 4,
+	SearchTypeCostume:     5,
+}
+
+// Match weights. Titles count double compared to secondary fields such as
+// music readings or creators.
+const (
+	scoreExactWord  = 3
+	scorePrefixWord = 2
+	scoreSubstring  = 1
+	scoreTitleField = 2
+	scoreOtherField = 1
+	scoreID         = 20
+	scoreFullTitle  = 10
+	scoreTitleStart = 5
+)
+
+// posting is one occurrence of a term
+type posting struct {
+	doc    int
+	weight int // field weight
+	whole  bool
+}
+
+// searchIndex is an inverted index over the names of every entity. Words
+// in scripts without spaces are indexed by all of their suffixes so that
+// prefix lookups also find matches in the middle of a title.
+type searchIndex struct {
+	docs     []models.SearchResult
+	titles   []string // normalized titles
+	postings map[string][]posting
+	terms    []string // sorted keys of postings
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{postings: make(map[string][]posting)}
+}
+
+// buildSearchIndex indexes the entities of a loaded data set
+func buildSearchIndex(raw rawData) *searchIndex {
+	idx := newSearchIndex()
+	for _, c := range raw.cards {
+		idx.add(SearchTypeCard, c.ID, c.Prefix, c.AssetbundleName, c.CardSkillName)
+	}
+	for _, e := range raw.events {
+		idx.add(SearchTypeEvent, e.ID, e.Name, e.AssetbundleName)
+	}
+	for _, g := range raw.gachas {
+		idx.add(SearchTypeGacha, g.ID, g.Name, g.AssetbundleName)
+	}
+	for _, m := range raw.musics {
+		idx.add(SearchTypeMusic, m.ID, m.Title, m.AssetbundleName, m.Pronunciation, m.Lyricist, m.Composer, m.Arranger)
+	}
+	// Costume parts of a group share their name; index one entry per group
+	seenGroups := make(map[int]bool)
+	for _, c := range raw.costume3ds {
+		if seenGroups[c.Costume3dGroupId] {
+			continue
+		}
+		seenGroups[c.Costume3dGroupId] = true
+		idx.add(SearchTypeCostume, c.ID, c.Name, c.AssetbundleName)
+	}
+	for _, vl := range raw.virtualLives {
+		idx.add(SearchTypeVirtualLive, vl.ID, vl.Name, vl.AssetbundleName)
+	}
+
+	idx.terms = make([]string, 0, len(idx.postings))
+	for term := range idx.postings {
+		idx.terms = append(idx.terms, term)
+	}
+	sort.Strings(idx.terms)
+	return idx
+}
+
+// add indexes an entity by its title and any number of secondary fields
+func (idx *searchIndex) add(kind string, id int, title, assetbundleName string, fields ...string) {
+	if title == "" {
+		return
+	}
+	doc := len(idx.docs)
+	idx.docs = append(idx.docs, models.SearchResult{
+		Type:            kind,
+		ID:              id,
+		Title:           title,
+		AssetbundleName: assetbundleName,
+	})
+	idx.titles = append(idx.titles, normalizeSearchText(title))
+
+	idx.addField(doc, title, scoreTitleField)
+	for _, field := range fields {
+		idx.addField(doc, field, scoreOtherField)
+	}
+}
+
+func (idx *searchIndex) addField(doc int, text string, weight int) {
+	seen := make(map[string]bool)
+	for _, word := range searchTokens(normalizeSearchText(text)) {
+		idx.addTerm(word, posting{doc: doc, weight: weight, whole: true}, seen)
+		if !hasSpacelessScript(word) {
+			continue
+		}
+		runes := []rune(word)
+		for i := 1; i < len(runes); i++ {
+			idx.addTerm(string(runes[i:]), posting{doc: doc, weight: weight}, seen)
+		}
+	}
+}
+
+func (idx *searchIndex) addTerm(term string, p posting, seen map[string]bool) {
+	if seen[term] {
+		return
+	}
+	seen[term] = true
+	idx.postings[term] = append(idx.postings[term], p)
+}
+
+// search returns the entities matching every word of query, best first
+func (idx *searchIndex) search(query string, types map[string]bool, limit int) []models.SearchResult {
+	normalized := normalizeSearchText(query)
+	words := searchTokens(normalized)
+	if len(words) == 0 {
+		return []models.SearchResult{}
+	}
+
+	var scores map[int]int
+	for _, word := range words {
+		wordScores := idx.lookup(word)
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+		for doc, score := range scores {
+			if s, ok := wordScores[doc]; ok {
+				scores[doc] = score + s
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	// Numeric queries also match ids exactly
+	if id, err := strconv.Atoi(strings.TrimSpace(query)); err == nil {
+		for doc, d := range idx.docs {
+			if d.ID == id {
+				scores[doc] += scoreID
+			}
+		}
+	}
+
+	results := make([]models.SearchResult, 0, len(scores))
+	for doc, score := range scores {
+		d := idx.docs[doc]
+		if types != nil && !types[d.Type] {
+			continue
+		}
+		switch title := idx.titles[doc]; {
+		case title == normalized:
+			score += scoreFullTitle
+		case strings.HasPrefix(title, normalized):
+			score += scoreTitleStart
+		}
+		d.Score = score
+		results = append(results, d)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Type != b.Type {
+			return searchTypeOrder[a.Type] < searchTypeOrder[b.Type]
+		}
+		// Newer entities first
+		return a.ID > b.ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// lookup scores the documents containing a term starting with word
+func (idx *searchIndex) lookup(word string) map[int]int {
+	scores := make(map[int]int)
+	for i := sort.SearchStrings(idx.terms, word); i < len(idx.terms) && strings.HasPrefix(idx.terms[i], word); i++ {
+		term := idx.terms[i]
+		for _, p := range idx.postings[term] {
+			var score int
+			switch {
+			case !p.whole:
+				score = scoreSubstring
+			case term == word:
+				score = scoreExactWord
+			default:
+				score = scorePrefixWord
+			}
+			score *= p.weight
+			if score > scores[p.doc] {
+				scores[p.doc] = score
+			}
+		}
+	}
+	return scores
+}
+
+// normalizeSearchText folds case, full-width ASCII and katakana so that
+// "ＭＯＲＥ" matches "more" and "ハロー" matches "はろー"
+func normalizeSearchText(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		switch {
+		case r >= 0xFF01 && r <= 0xFF5E:
+			// Full-width ASCII variants
+			r -= 0xFEE0
+		case r == 0x3000:
+			r = ' '
+		case r >= 0xFF66 && r <= 0xFF9D:
+			r = halfwidthKatakana[r-0xFF66]
+		}
+		if r >= 0x30A1 && r <= 0x30F6 {
+			// Katakana to hiragana
+			r -= 0x60
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// halfwidthKatakana maps U+FF66..U+FF9D to full-width katakana
+var halfwidthKatakana = []rune("ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン")
+
+// searchTokens splits normalized text into words at spaces and punctuation
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != 'ー' && r != '々'
+	})
+}
+
+// hasSpacelessScript reports whether a word contains kana or CJK
+// ideographs, which are written without spaces between words
+func hasSpacelessScript(word string) bool {
+	for _, r := range word {
+		if unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han, unicode.Hangul) {
+			return true
+		}
+	}
+	return false
+}
+
+// Search queries every indexed entity. types restricts the result types
+// when not nil; limit <= 0 returns all matches.
+func (s *Store) Search(query string, types map[string]bool, limit int) []models.SearchResult {
+	s.mutex.RLock()
+	idx := s.searchIndex
+	s.mutex.RUnlock()
+	return idx.search(query, types, limit)
+}
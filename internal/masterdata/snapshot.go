@@ -0,0 +1,162 @@
+package masterdata
+
+import (
+	"fmt"
+	"time"
+
+	"snowy_viewer/internal/models"
+)
+
+// File sources reported in FileStatus
+const (
+	FileSourceLocal  = "local"
+	FileSourceRemote = "remote"
+	// FileSourceMirror is a previously mirrored copy used while the
+	// remote is unavailable
+	FileSourceMirror = "mirror"
+)
+
+// FileStatus describes how a master data file was loaded
+type FileStatus struct {
+	Name        string    `json:"name"`
+	Source      string    `json:"source"`
+	Location    string    `json:"location"`
+	Size        int64     `json:"size"`
+	Outcome     string    `json:"outcome"`
+	Records     int       `json:"records"`
+	Warnings    []string  `json:"warnings,omitempty"`
+	Mirrored    bool      `json:"mirrored,omitempty"`
+	NotModified bool      `json:"notModified,omitempty"`
+	Resumed     bool      `json:"resumed,omitempty"`
+	Attempts    int       `json:"attempts,omitempty"`
+	LocalError  string    `json:"localError,omitempty"`
+	RemoteError string    `json:"remoteError,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	LoadedAt    time.Time `json:"loadedAt"`
+}
+
+// snapshot is a complete set of loaded master data that can be restored
+type snapshot struct {
+	version     string
+	files       []FileStatus
+	lastRefresh time.Time
+	raw         rawData
+
+	eventList           []models.Event
+	eventCardIdsMap     map[int][]int
+	eventMusicIdsMap    map[int][]int
+	eventDeckBonusMap   map[int][]models.EventDeckBonus
+	characterUnitMap    map[int]models.GameCharacterUnit
+	musicList           []models.Music
+	musicTagMap         map[int][]string
+	musicDifficultyMap  map[int][]models.MusicDifficulty
+	musicVocalMap       map[int][]models.MusicVocal
+	searchIndex         *searchIndex
+	cardEventMap        map[int]models.EventInfo
+	musicEventMap       map[int][]models.EventInfo
+	cardGachaMap        map[int][]models.GachaInfo
+	eventVirtualLiveMap map[int]models.VirtualLiveInfo
+	virtualLiveEventMap map[int]models.EventInfo
+	gachaList           []models.Gacha
+	gachaPickups        map[int][]int
+	cardCostume3dMap    map[int][]int
+	costume3dGroupIdMap map[int]int
+	costume3dGroupMap   map[int][]models.Costume3d
+	cardList            []models.Card
+	cardSupplyTypeMap   map[int]string
+	skillMap            map[int]models.Skill
+}
+
+// capture copies the current data into a snapshot. Callers must hold s.mutex.
+func (s *Store) capture() snapshot {
+	return snapshot{
+		version:             s.version,
+		files:               s.files,
+		lastRefresh:         s.lastRefresh,
+		raw:                 s.raw,
+		eventList:           s.EventList,
+		eventCardIdsMap:     s.EventCardIdsMap,
+		eventMusicIdsMap:    s.EventMusicIdsMap,
+		eventDeckBonusMap:   s.EventDeckBonusMap,
+		characterUnitMap:    s.CharacterUnitMap,
+		musicList:           s.MusicList,
+		musicTagMap:         s.MusicTagMap,
+		musicDifficultyMap:  s.MusicDifficultyMap,
+		musicVocalMap:       s.MusicVocalMap,
+		searchIndex:         s.searchIndex,
+		cardEventMap:        s.CardEventMap,
+		musicEventMap:       s.MusicEventMap,
+		cardGachaMap:        s.CardGachaMap,
+		eventVirtualLiveMap: s.EventVirtualLiveMap,
+		virtualLiveEventMap: s.VirtualLiveEventMap,
+		gachaList:           s.GachaList,
+		gachaPickups:        s.GachaPickups,
+		cardCostume3dMap:    s.CardCostume3dMap,
+		costume3dGroupIdMap: s.Costume3dGroupIdMap,
+		costume3dGroupMap:   s.Costume3dGroupMap,
+		cardList:            s.CardList,
+		cardSupplyTypeMap:   s.CardSupplyTypeMap,
+		skillMap:            s.SkillMap,
+	}
+}
+
+// apply replaces the current data with a snapshot. Callers must hold s.mutex.
+func (s *Store) apply(snap snapshot) {
+	s.version = snap.version
+	s.files = snap.files
+	s.lastRefresh = snap.lastRefresh
+	s.raw = snap.raw
+	s.EventList = snap.eventList
+	s.EventCardIdsMap = snap.eventCardIdsMap
+	s.EventMusicIdsMap = snap.eventMusicIdsMap
+	s.EventDeckBonusMap = snap.eventDeckBonusMap
+	s.CharacterUnitMap = snap.characterUnitMap
+	s.MusicList = snap.musicList
+	s.MusicTagMap = snap.musicTagMap
+	s.MusicDifficultyMap = snap.musicDifficultyMap
+	s.MusicVocalMap = snap.musicVocalMap
+	s.searchIndex = snap.searchIndex
+	s.CardEventMap = snap.cardEventMap
+	s.MusicEventMap = snap.musicEventMap
+	s.CardGachaMap = snap.cardGachaMap
+	s.EventVirtualLiveMap = snap.eventVirtualLiveMap
+	s.VirtualLiveEventMap = snap.virtualLiveEventMap
+	s.GachaList = snap.gachaList
+	s.GachaPickups = snap.gachaPickups
+	s.CardCostume3dMap = snap.cardCostume3dMap
+	s.Costume3dGroupIdMap = snap.costume3dGroupIdMap
+	s.Costume3dGroupMap = snap.costume3dGroupMap
+	s.CardList = snap.cardList
+	s.CardSupplyTypeMap = snap.cardSupplyTypeMap
+	s.SkillMap = snap.skillMap
+}
+
+// Rollback restores the previously loaded data. The current data becomes
+// the new rollback target, so a second call undoes the first. The version
+// rolled back from is skipped by periodic refreshes and restarts until the
+// next Fetch; the mirror is rolled back with the data so that a restart
+// loads the restored files.
+func (s *Store) Rollback() error {
+	s.fetchMutex.Lock()
+	defer s.fetchMutex.Unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.previous == nil {
+		return fmt.Errorf("no previous snapshot")
+	}
+	current := s.capture()
+	if len(s.mirror.meta.Files) > 0 {
+		if err := s.mirror.rollback(current.version); err != nil {
+			return fmt.Errorf("roll back mirror: %v", err)
+		}
+	}
+	s.apply(*s.previous)
+	s.previous = &current
+	s.rejected = current.version
+	s.lastError = ""
+
+	fmt.Printf("[%s] Rolled back master data from %q to %q\n", s.server, current.version, s.version)
+	return nil
+}
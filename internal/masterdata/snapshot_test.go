@@ -0,0 +1,190 @@
+package masterdata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeUpstream serves one generation of master data files, tagged with the
+// generation as ETag
+type fakeUpstream struct {
+	mu    sync.Mutex
+	gen   string
+	files map[string]string
+}
+
+func (u *fakeUpstream) set(gen string, files map[string]string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.gen, u.files = gen, files
+}
+
+func (u *fakeUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	// Version checks probe every file, including the ones not served
+	w.Header().Set("ETag", `"`+u.gen+`"`)
+	if r.Method == http.MethodHead {
+		return
+	}
+	body, ok := u.files[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte(body))
+}
+
+// generation returns the files of a data set whose only event is named name
+func generation(name string) map[string]string {
+	return map[string]string{
+		"events.json":      `[{"id": 1, "name": "` + name + `"}]`,
+		"eventCards.json":  `[{"id": 1, "cardId": 1, "eventId": 1}]`,
+		"eventMusics.json": `[{"eventId": 1, "musicId": 1}]`,
+	}
+}
+
+// newTestStore serves upstream as the tw server and returns a function
+// opening a store on the same data directory, as a restart would
+func newTestStore(t *testing.T, upstream *fakeUpstream) func() *Store {
+	t.Helper()
+	srv := httptest.NewServer(upstream)
+	t.Cleanup(srv.Close)
+
+	src := Sources[ServerTW]
+	t.Cleanup(func() { Sources[ServerTW] = src })
+	Sources[ServerTW] = Source{Mirrors: []string{srv.URL}}
+	retries := FetchRetries
+	t.Cleanup(func() { FetchRetries = retries })
+	FetchRetries = 0
+
+	dir := t.TempDir() + "/master-tw"
+	return func() *Store { return NewStore(ServerTW, dir) }
+}
+
+func eventName(s *Store) string {
+	events := s.GetEventList()
+	if len(events) == 0 {
+		return ""
+	}
+	return events[0].Name
+}
+
+func TestRollbackSurvivesRestart(t *testing.T) {
+	upstream := &fakeUpstream{}
+	upstream.set("a", generation("A"))
+	open := newTestStore(t, upstream)
+
+	store := open()
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	upstream.set("b", generation("B"))
+	if updated, err := store.Refresh(); err != nil || !updated {
+		t.Fatalf("Refresh = %v, %v", updated, err)
+	}
+	if name := eventName(store); name != "B" {
+		t.Fatalf("after refresh event = %q, want B", name)
+	}
+	rejected := store.Status().Version
+
+	if err := store.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if name := eventName(store); name != "A" {
+		t.Fatalf("after rollback event = %q, want A", name)
+	}
+
+	// A restart keeps serving the rolled back data while B is current
+	restarted := open()
+	if err := restarted.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if name := eventName(restarted); name != "A" {
+		t.Errorf("after restart event = %q, want A", name)
+	}
+	status := restarted.DetailedStatus()
+	if status.RejectedVersion != rejected {
+		t.Errorf("rejected version = %q, want %q", status.RejectedVersion, rejected)
+	}
+	if updated, err := restarted.Refresh(); err != nil || updated {
+		t.Errorf("Refresh of the rejected version = %v, %v", updated, err)
+	}
+
+	// A new upstream version is loaded as usual
+	upstream.set("c", generation("C"))
+	if updated, err := restarted.Refresh(); err != nil || !updated {
+		t.Fatalf("Refresh = %v, %v", updated, err)
+	}
+	if name := eventName(restarted); name != "C" {
+		t.Errorf("after new version event = %q, want C", name)
+	}
+}
+
+func TestRollbackTwiceRestoresMirror(t *testing.T) {
+	upstream := &fakeUpstream{}
+	upstream.set("a", generation("A"))
+	open := newTestStore(t, upstream)
+
+	store := open()
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	upstream.set("b", generation("B"))
+	if _, err := store.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if name := eventName(store); name != "B" {
+		t.Fatalf("after two rollbacks event = %q, want B", name)
+	}
+
+	// The upstream moving back to A is not loaded again, B stays
+	upstream.set("a", generation("A"))
+	restarted := open()
+	if err := restarted.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if name := eventName(restarted); name != "B" {
+		t.Errorf("after restart event = %q, want B", name)
+	}
+}
+
+func TestFetchClearsPersistedRollback(t *testing.T) {
+	upstream := &fakeUpstream{}
+	upstream.set("a", generation("A"))
+	open := newTestStore(t, upstream)
+
+	store := open()
+	if err := store.Load(); err != nil {
+		t.Fatal(err)
+	}
+	upstream.set("b", generation("B"))
+	if _, err := store.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	if name := eventName(store); name != "B" {
+		t.Fatalf("after fetch event = %q, want B", name)
+	}
+
+	restarted := open()
+	if err := restarted.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if name := eventName(restarted); name != "B" || restarted.DetailedStatus().RejectedVersion != "" {
+		t.Errorf("after restart event = %q, rejected %q", name, restarted.DetailedStatus().RejectedVersion)
+	}
+}
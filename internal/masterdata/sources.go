@@ -0,0 +1,168 @@
+package masterdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Supported game servers
+const (
+	ServerJP = "jp"
+	ServerCN = "cn"
+	ServerTW = "tw"
+	ServerEN = "en"
+	ServerKR = "kr"
+)
+
+// DefaultServer is used when a request does not select a server
+const DefaultServer = ServerJP
+
+// Source holds the upstream base URLs for one server's master data
+type Source struct {
+	// MasterURL serves the frequently updated event files. Empty means
+	// the server has no dedicated master host and GithubURL is used instead.
+	MasterURL string
+	// FallbackURL mirrors MasterURL on a second domain for ISP blocking
+	FallbackURL string
+	// GithubURL serves every other file from the raw GitHub repository
+	GithubURL string
+	// VersionURL points to current_version.json on the master host. Servers
+	// without one fall back to the ETag/Last-Modified headers of their files.
+	VersionURL string
+
+	// Mirrors replaces the default ordered list of base URLs for every file
+	Mirrors []string
+	// FileMirrors replaces the ordered list of base URLs for single files
+	FileMirrors map[string][]string
+}
+
+// Sources maps each supported server to its upstream locations
+var Sources = map[string]Source{
+	ServerJP: {
+		MasterURL:   "https://sekaimaster.exmeaning.com/master",
+		FallbackURL: "https://sk.exmeaning.com/master",
+		GithubURL:   "https://raw.githubusercontent.com/Team-Haruki/haruki-sekai-master/main/master",
+		VersionURL:  "https://sekaimaster.exmeaning.com/versions/current_version.json",
+	},
+	ServerCN: {
+		MasterURL:   "https://sekaimaster-cn.exmeaning.com/master",
+		FallbackURL: "https://sk-cn.exmeaning.com/master",
+		GithubURL:   "https://raw.githubusercontent.com/Team-Haruki/haruki-sekai-sc-master/main/master",
+		VersionURL:  "https://sekaimaster-cn.exmeaning.com/versions/current_version.json",
+	},
+	ServerTW: {
+		GithubURL: "https://raw.githubusercontent.com/Team-Haruki/haruki-sekai-tc-master/main/master",
+	},
+	ServerEN: {
+		GithubURL: "https://raw.githubusercontent.com/Team-Haruki/haruki-sekai-en-master/main/master",
+	},
+	ServerKR: {
+		GithubURL: "https://raw.githubusercontent.com/Team-Haruki/haruki-sekai-kr-master/main/master",
+	},
+}
+
+// Files loaded by Store.Fetch
+var masterFiles = []string{
+	"events.json",
+	"eventCards.json",
+	"eventMusics.json",
+	"virtualLives.json",
+	"gachas.json",
+	"cardCostume3ds.json",
+	"costume3ds.json",
+	"cards.json",
+	"cardSupplies.json",
+	"skills.json",
+	"eventDeckBonuses.json",
+	"gameCharacterUnits.json",
+	"musics.json",
+	"musicTags.json",
+	"musicDifficulties.json",
+	"musicVocals.json",
+}
+
+// Files served from the master host when the server has one
+var masterHostedFiles = map[string]bool{
+	"events.json":      true,
+	"eventCards.json":  true,
+	"eventMusics.json": true,
+}
+
+// URLs returns the remote locations of a master data file in the order
+// they should be tried
+func (src Source) URLs(filename string) []string {
+	bases := src.FileMirrors[filename]
+	if len(bases) == 0 {
+		bases = src.Mirrors
+	}
+	if len(bases) == 0 {
+		bases = src.defaultBases(filename)
+	}
+	urls := make([]string, 0, len(bases))
+	for _, base := range bases {
+		urls = append(urls, strings.TrimRight(base, "/")+"/"+filename)
+	}
+	return urls
+}
+
+// URL returns the primary remote location of a master data file
+func (src Source) URL(filename string) string {
+	return src.URLs(filename)[0]
+}
+
+func (src Source) defaultBases(filename string) []string {
+	var bases []string
+	if src.MasterURL != "" && masterHostedFiles[filename] {
+		bases = append(bases, src.MasterURL)
+		if src.FallbackURL != "" {
+			bases = append(bases, src.FallbackURL)
+		}
+		return append(bases, src.GithubURL)
+	}
+	bases = append(bases, src.GithubURL)
+	if src.MasterURL != "" {
+		bases = append(bases, src.MasterURL)
+	}
+	if src.FallbackURL != "" {
+		bases = append(bases, src.FallbackURL)
+	}
+	return bases
+}
+
+// sourceOverride is one server's entry in the MASTER_SOURCES_FILE
+type sourceOverride struct {
+	Default []string            `json:"default"`
+	Files   map[string][]string `json:"files"`
+}
+
+// LoadSourceOverrides reads ordered mirror lists from a JSON file of the form
+// {"jp": {"default": ["https://a/master"], "files": {"gachas.json": [...]}}}
+func LoadSourceOverrides(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var overrides map[string]sourceOverride
+	if err := json.Unmarshal(content, &overrides); err != nil {
+		return fmt.Errorf("parse %s: %v", path, err)
+	}
+	for server, override := range overrides {
+		server = strings.ToLower(server)
+		src, ok := Sources[server]
+		if !ok {
+			return fmt.Errorf("parse %s: unknown server %q", path, server)
+		}
+		src.Mirrors = override.Default
+		src.FileMirrors = override.Files
+		Sources[server] = src
+	}
+	return nil
+}
+
+// IsValidServer reports whether the server identifier is supported
+func IsValidServer(server string) bool {
+	_, ok := Sources[strings.ToLower(server)]
+	return ok
+}
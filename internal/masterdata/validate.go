@@ -0,0 +1,395 @@
+package masterdata
+
+import (
+	"errors"
+	"fmt"
+
+	"snowy_viewer/internal/models"
+)
+
+// Load outcomes reported in FileStatus
+const (
+	OutcomeLoaded       = "loaded"
+	OutcomeKeptPrevious = "kept-previous"
+	OutcomeMissing      = "missing"
+)
+
+const (
+	// A file shrinking below this share of its previous record count is
+	// treated as truncated upstream data
+	minRetainedRatio = 0.5
+	// Share of dangling references tolerated before a file is rejected
+	maxDanglingRatio = 0.05
+)
+
+var errEmpty = errors.New("no records")
+
+// rawData holds the last accepted contents of every master data file
+type rawData struct {
+	events         []models.Event
+	eventCards     []models.EventCard
+	eventMusics    []models.EventMusic
+	virtualLives   []models.VirtualLive
+	gachas         []models.Gacha
+	costume3ds     []models.Costume3d
+	cardCostume3ds []models.CardCostume3d
+	cards          []models.Card
+	cardSupplies   []models.CardSupply
+	skills         []models.Skill
+	deckBonuses    []models.EventDeckBonus
+	characterUnits []models.GameCharacterUnit
+	musics         []models.Music
+	musicTags      []models.MusicTag
+	difficulties   []models.MusicDifficulty
+	vocals         []models.MusicVocal
+}
+
+// validator checks a decoded file and returns non-fatal warnings
+type validator[T any] func(items []T) ([]string, error)
+
+// loadFile loads and validates a file. When the new contents are unusable
+// the previous good version is kept; an error is returned only if there is
+// nothing to fall back to.
+func loadFile[T any](s *Store, filename string, previous []T, validate validator[T]) ([]T, error) {
+	var items []T
+	status, err := s.loadOrFetch(filename, &items)
+	if err == nil {
+		status.Warnings, err = validate(items)
+	}
+	if err == nil && len(previous) > 0 && float64(len(items)) < float64(len(previous))*minRetainedRatio {
+		err = fmt.Errorf("suspicious: %d records, previously %d", len(items), len(previous))
+	}
+	defer func() { s.loading = append(s.loading, status) }()
+
+	if err == nil {
+		status.Outcome = OutcomeLoaded
+		status.Records = len(items)
+		if status.Source == FileSourceRemote {
+			mirrored, commitErr := s.mirror.commit(filename)
+			if commitErr != nil {
+				fmt.Printf("[%s] Warning: failed to mirror %s: %v\n", s.server, filename, commitErr)
+			}
+			status.Mirrored = mirrored
+		}
+		return items, nil
+	}
+
+	s.mirror.discard(filename)
+	status.Error = err.Error()
+	if len(previous) > 0 {
+		fmt.Printf("[%s] Warning: rejected %s (%v), keeping previous version\n", s.server, filename, err)
+		status.Outcome = OutcomeKeptPrevious
+		status.Records = len(previous)
+		return previous, nil
+	}
+	status.Outcome = OutcomeMissing
+	return nil, err
+}
+
+// checkRecords rejects empty files and the first record failing check
+func checkRecords[T any](items []T, check func(T) error) error {
+	if len(items) == 0 {
+		return errEmpty
+	}
+	for i, item := range items {
+		if err := check(item); err != nil {
+			return fmt.Errorf("record %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// checkReferences turns a dangling reference count into a warning, or an
+// error once it exceeds maxDanglingRatio
+func checkReferences(target string, dangling, total int) ([]string, error) {
+	if dangling == 0 {
+		return nil, nil
+	}
+	msg := fmt.Sprintf("%d of %d records reference unknown %s", dangling, total, target)
+	if float64(dangling) > float64(total)*maxDanglingRatio {
+		return nil, errors.New(msg)
+	}
+	return []string{msg}, nil
+}
+
+func validateEvents(events []models.Event) ([]string, error) {
+	seen := make(map[int]bool, len(events))
+	return nil, checkRecords(events, func(e models.Event) error {
+		if e.ID <= 0 || e.Name == "" {
+			return errors.New("missing id or name")
+		}
+		if seen[e.ID] {
+			return fmt.Errorf("duplicate id %d", e.ID)
+		}
+		seen[e.ID] = true
+		return nil
+	})
+}
+
+func validateEventCards(events []models.Event) validator[models.EventCard] {
+	return func(eventCards []models.EventCard) ([]string, error) {
+		err := checkRecords(eventCards, func(ec models.EventCard) error {
+			if ec.CardID <= 0 || ec.EventID <= 0 {
+				return errors.New("missing cardId or eventId")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		ids := eventIDs(events)
+		dangling := 0
+		for _, ec := range eventCards {
+			if !ids[ec.EventID] {
+				dangling++
+			}
+		}
+		return checkReferences("events", dangling, len(eventCards))
+	}
+}
+
+func validateEventMusics(events []models.Event) validator[models.EventMusic] {
+	return func(eventMusics []models.EventMusic) ([]string, error) {
+		err := checkRecords(eventMusics, func(em models.EventMusic) error {
+			if em.MusicID <= 0 || em.EventID <= 0 {
+				return errors.New("missing musicId or eventId")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		ids := eventIDs(events)
+		dangling := 0
+		for _, em := range eventMusics {
+			if !ids[em.EventID] {
+				dangling++
+			}
+		}
+		return checkReferences("events", dangling, len(eventMusics))
+	}
+}
+
+func validateVirtualLives(events []models.Event) validator[models.VirtualLive] {
+	return func(virtualLives []models.VirtualLive) ([]string, error) {
+		err := checkRecords(virtualLives, func(vl models.VirtualLive) error {
+			if vl.ID <= 0 {
+				return errors.New("missing id")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Events reference virtual lives, so only report the gap
+		ids := make(map[int]bool, len(virtualLives))
+		for _, vl := range virtualLives {
+			ids[vl.ID] = true
+		}
+		linked, dangling := 0, 0
+		for _, e := range events {
+			if e.VirtualLiveId > 0 {
+				linked++
+				if !ids[e.VirtualLiveId] {
+					dangling++
+				}
+			}
+		}
+		if dangling > 0 {
+			return []string{fmt.Sprintf("%d of %d events reference unknown virtual lives", dangling, linked)}, nil
+		}
+		return nil, nil
+	}
+}
+
+func validateGachas(gachas []models.Gacha) ([]string, error) {
+	return nil, checkRecords(gachas, func(g models.Gacha) error {
+		if g.ID <= 0 || g.Name == "" {
+			return errors.New("missing id or name")
+		}
+		return nil
+	})
+}
+
+func validateCostume3ds(costume3ds []models.Costume3d) ([]string, error) {
+	return nil, checkRecords(costume3ds, func(c models.Costume3d) error {
+		if c.ID <= 0 || c.Costume3dGroupId <= 0 {
+			return errors.New("missing id or costume3dGroupId")
+		}
+		return nil
+	})
+}
+
+func validateCardCostume3ds(costume3ds []models.Costume3d) validator[models.CardCostume3d] {
+	return func(cardCostume3ds []models.CardCostume3d) ([]string, error) {
+		err := checkRecords(cardCostume3ds, func(cc models.CardCostume3d) error {
+			if cc.CardID <= 0 || cc.Costume3dID <= 0 {
+				return errors.New("missing cardId or costume3dId")
+			}
+			return nil
+		})
+		if err != nil || len(costume3ds) == 0 {
+			return nil, err
+		}
+		ids := make(map[int]bool, len(costume3ds))
+		for _, c := range costume3ds {
+			ids[c.ID] = true
+		}
+		dangling := 0
+		for _, cc := range cardCostume3ds {
+			if !ids[cc.Costume3dID] {
+				dangling++
+			}
+		}
+		return checkReferences("costume3ds", dangling, len(cardCostume3ds))
+	}
+}
+
+func validateCards(cards []models.Card) ([]string, error) {
+	seen := make(map[int]bool, len(cards))
+	return nil, checkRecords(cards, func(c models.Card) error {
+		if c.ID <= 0 || c.CharacterID <= 0 || c.CardRarityType == "" {
+			return errors.New("missing id, characterId or cardRarityType")
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("duplicate id %d", c.ID)
+		}
+		seen[c.ID] = true
+		return nil
+	})
+}
+
+func validateCardSupplies(cardSupplies []models.CardSupply) ([]string, error) {
+	return nil, checkRecords(cardSupplies, func(cs models.CardSupply) error {
+		if cs.ID <= 0 || cs.CardSupplyType == "" {
+			return errors.New("missing id or cardSupplyType")
+		}
+		return nil
+	})
+}
+
+func validateSkills(skills []models.Skill) ([]string, error) {
+	return nil, checkRecords(skills, func(sk models.Skill) error {
+		if sk.ID <= 0 {
+			return errors.New("missing id")
+		}
+		return nil
+	})
+}
+
+func validateEventDeckBonuses(events []models.Event) validator[models.EventDeckBonus] {
+	return func(deckBonuses []models.EventDeckBonus) ([]string, error) {
+		err := checkRecords(deckBonuses, func(b models.EventDeckBonus) error {
+			if b.EventID <= 0 || (b.GameCharacterUnitID <= 0 && b.CardAttr == "") {
+				return errors.New("missing eventId or bonus target")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		ids := eventIDs(events)
+		dangling := 0
+		for _, b := range deckBonuses {
+			if !ids[b.EventID] {
+				dangling++
+			}
+		}
+		return checkReferences("events", dangling, len(deckBonuses))
+	}
+}
+
+func validateGameCharacterUnits(characterUnits []models.GameCharacterUnit) ([]string, error) {
+	return nil, checkRecords(characterUnits, func(cu models.GameCharacterUnit) error {
+		if cu.ID <= 0 || cu.GameCharacterID <= 0 {
+			return errors.New("missing id or gameCharacterId")
+		}
+		return nil
+	})
+}
+
+func validateMusics(musics []models.Music) ([]string, error) {
+	seen := make(map[int]bool, len(musics))
+	return nil, checkRecords(musics, func(m models.Music) error {
+		if m.ID <= 0 || m.Title == "" {
+			return errors.New("missing id or title")
+		}
+		if seen[m.ID] {
+			return fmt.Errorf("duplicate id %d", m.ID)
+		}
+		seen[m.ID] = true
+		return nil
+	})
+}
+
+func validateMusicTags(musics []models.Music) validator[models.MusicTag] {
+	return func(musicTags []models.MusicTag) ([]string, error) {
+		err := checkRecords(musicTags, func(t models.MusicTag) error {
+			if t.MusicID <= 0 || t.MusicTag == "" {
+				return errors.New("missing musicId or musicTag")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return checkMusicReferences(musics, len(musicTags), func(i int) int { return musicTags[i].MusicID })
+	}
+}
+
+func validateMusicDifficulties(musics []models.Music) validator[models.MusicDifficulty] {
+	return func(difficulties []models.MusicDifficulty) ([]string, error) {
+		err := checkRecords(difficulties, func(d models.MusicDifficulty) error {
+			if d.MusicID <= 0 || d.MusicDifficulty == "" || d.PlayLevel <= 0 {
+				return errors.New("missing musicId, musicDifficulty or playLevel")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return checkMusicReferences(musics, len(difficulties), func(i int) int { return difficulties[i].MusicID })
+	}
+}
+
+func validateMusicVocals(musics []models.Music) validator[models.MusicVocal] {
+	return func(vocals []models.MusicVocal) ([]string, error) {
+		err := checkRecords(vocals, func(v models.MusicVocal) error {
+			if v.ID <= 0 || v.MusicID <= 0 {
+				return errors.New("missing id or musicId")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return checkMusicReferences(musics, len(vocals), func(i int) int { return vocals[i].MusicID })
+	}
+}
+
+// checkMusicReferences counts the records whose music id is unknown. It is
+// skipped when musics.json itself is unavailable.
+func checkMusicReferences(musics []models.Music, total int, musicID func(int) int) ([]string, error) {
+	if len(musics) == 0 {
+		return nil, nil
+	}
+	ids := make(map[int]bool, len(musics))
+	for _, m := range musics {
+		ids[m.ID] = true
+	}
+	dangling := 0
+	for i := 0; i < total; i++ {
+		if !ids[musicID(i)] {
+			dangling++
+		}
+	}
+	return checkReferences("musics", dangling, total)
+}
+
+func eventIDs(events []models.Event) map[int]bool {
+	ids := make(map[int]bool, len(events))
+	for _, e := range events {
+		ids[e.ID] = true
+	}
+	return ids
+}
@@ -0,0 +1,178 @@
+package masterdata
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Status describes the refresh state of a store
+type Status struct {
+	Server      string    `json:"server"`
+	Version     string    `json:"version"`
+	LastRefresh time.Time `json:"lastRefresh"`
+	LastCheck   time.Time `json:"lastCheck"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// DetailedStatus adds per-file load information and rollback state
+type DetailedStatus struct {
+	Status
+	Files           []FileStatus `json:"files"`
+	FailedFiles     []FileStatus `json:"failedFiles,omitempty"`
+	PreviousVersion *string      `json:"previousVersion"`
+	RejectedVersion string       `json:"rejectedVersion,omitempty"`
+}
+
+type versionInfo struct {
+	DataVersion string `json:"dataVersion"`
+}
+
+var versionClient = &http.Client{Timeout: 15 * time.Second}
+
+// versionMarkerPath returns the local version marker of a data directory,
+// e.g. ./data/master -> ./data/master_version.txt
+func versionMarkerPath(localDataPath string) string {
+	return strings.TrimRight(localDataPath, "/\\") + "_version.txt"
+}
+
+// currentVersion builds a fingerprint of the local marker and the upstream
+// version. It fails only when neither of them could be determined.
+func (s *Store) currentVersion() (string, error) {
+	var parts []string
+
+	if content, err := os.ReadFile(versionMarkerPath(s.localDataPath)); err == nil {
+		if marker := strings.TrimSpace(string(content)); marker != "" {
+			parts = append(parts, "local:"+marker)
+		}
+	}
+
+	remote, err := s.remoteVersion()
+	if err == nil {
+		parts = append(parts, "remote:"+remote)
+	}
+
+	if len(parts) == 0 {
+		return "", fmt.Errorf("version check: %v", err)
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+// remoteVersion reads current_version.json when the server publishes one,
+// otherwise it hashes the ETag/Last-Modified headers of every file
+func (s *Store) remoteVersion() (string, error) {
+	if s.source.VersionURL != "" {
+		resp, err := versionClient.Get(s.source.VersionURL)
+		if err == nil {
+			defer resp.Body.Close()
+			var info versionInfo
+			if resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&info) == nil && info.DataVersion != "" {
+				return info.DataVersion, nil
+			}
+		}
+	}
+
+	hash := sha1.New()
+	for _, filename := range masterFiles {
+		marker, err := headMarker(s.source.URLs(filename))
+		if err != nil {
+			return "", fmt.Errorf("head %s: %v", filename, err)
+		}
+		fmt.Fprintf(hash, "%s=%s\n", filename, marker)
+	}
+	return "etag-" + hex.EncodeToString(hash.Sum(nil))[:12], nil
+}
+
+// headMarker returns the ETag or Last-Modified of the first mirror that
+// answers a HEAD request
+func headMarker(urls []string) (string, error) {
+	var lastErr error
+	for _, url := range urls {
+		resp, err := versionClient.Head(url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("%s: %s", url, resp.Status)
+			continue
+		}
+		marker := resp.Header.Get("ETag")
+		if marker == "" {
+			marker = resp.Header.Get("Last-Modified")
+		}
+		if marker == "" {
+			lastErr = fmt.Errorf("%s: no ETag or Last-Modified", url)
+			continue
+		}
+		return marker, nil
+	}
+	return "", lastErr
+}
+
+// Refresh reloads the store only if its version marker changed since the
+// last successful load and was not rolled back. It returns whether a reload
+// happened.
+func (s *Store) Refresh() (bool, error) {
+	version, err := s.currentVersion()
+
+	s.mutex.Lock()
+	s.lastCheck = time.Now()
+	unchanged := err == nil && s.version != "" && (version == s.version || version == s.rejected)
+	if err != nil {
+		s.lastError = err.Error()
+	} else if unchanged {
+		s.lastError = ""
+	}
+	s.mutex.Unlock()
+
+	if unchanged {
+		return false, nil
+	}
+	if err != nil {
+		// Nothing to compare against; keep serving what we have
+		return false, err
+	}
+	return true, s.fetchVersion(version)
+}
+
+// Status returns the current refresh status
+func (s *Store) Status() Status {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return Status{
+		Server:      s.server,
+		Version:     s.version,
+		LastRefresh: s.lastRefresh,
+		LastCheck:   s.lastCheck,
+		LastError:   s.lastError,
+	}
+}
+
+// DetailedStatus returns the refresh status with per-file information
+func (s *Store) DetailedStatus() DetailedStatus {
+	status := s.Status()
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	detailed := DetailedStatus{
+		Status:          status,
+		Files:           s.files,
+		FailedFiles:     s.failedFiles,
+		RejectedVersion: s.rejected,
+	}
+	if s.previous != nil {
+		previousVersion := s.previous.version
+		detailed.PreviousVersion = &previousVersion
+	}
+	if detailed.Files == nil {
+		detailed.Files = []FileStatus{}
+	}
+	return detailed
+}
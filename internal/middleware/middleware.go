@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"compress/gzip"
+	"crypto/subtle"
 	"io"
 	"net/http"
 	"os"
@@ -57,6 +58,25 @@ func CORS(next http.Handler) http.Handler {
 	})
 }
 
+// RequireToken rejects requests without a matching bearer token or
+// X-Admin-Token header. An empty token rejects every request.
+func RequireToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := r.Header.Get("X-Admin-Token")
+			if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				provided = strings.TrimPrefix(auth, "Bearer ")
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Chain applies multiple middlewares in order
 func Chain(h http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
 	for i := len(middlewares) - 1; i >= 0; i-- {
@@ -1,11 +1,37 @@
 package models
 
+import "encoding/json"
+
 // Master Data Structs
 type Event struct {
+	ID                        int    `json:"id"`
+	EventType                 string `json:"eventType"`
+	Name                      string `json:"name"`
+	AssetbundleName           string `json:"assetbundleName"`
+	BgmAssetbundleName        string `json:"bgmAssetbundleName"`
+	EventPointAssetbundleName string `json:"eventPointAssetbundleName"`
+	StartAt                   int64  `json:"startAt"`
+	AggregateAt               int64  `json:"aggregateAt"`
+	RankingAnnounceAt         int64  `json:"rankingAnnounceAt"`
+	DistributionStartAt       int64  `json:"distributionStartAt"`
+	ClosedAt                  int64  `json:"closedAt"`
+	DistributionEndAt         int64  `json:"distributionEndAt"`
+	VirtualLiveId             int    `json:"virtualLiveId"`
+	Unit                      string `json:"unit"`
+}
+
+type EventDeckBonus struct {
+	ID                  int     `json:"id"`
+	EventID             int     `json:"eventId"`
+	GameCharacterUnitID int     `json:"gameCharacterUnitId,omitempty"`
+	CardAttr            string  `json:"cardAttr,omitempty"`
+	BonusRate           float64 `json:"bonusRate"`
+}
+
+type GameCharacterUnit struct {
 	ID              int    `json:"id"`
-	Name            string `json:"name"`
-	AssetbundleName string `json:"assetbundleName"`
-	VirtualLiveId   int    `json:"virtualLiveId"`
+	GameCharacterID int    `json:"gameCharacterId"`
+	Unit            string `json:"unit"`
 }
 
 type EventMusic struct {
@@ -88,6 +114,43 @@ type Costume3d struct {
 	ArchivePublishedAt int64  `json:"archivePublishedAt"`
 }
 
+// Card Structs
+type Card struct {
+	ID                              int             `json:"id"`
+	Seq                             int             `json:"seq"`
+	CharacterID                     int             `json:"characterId"`
+	CardRarityType                  string          `json:"cardRarityType"`
+	SpecialTrainingPower1BonusFixed int             `json:"specialTrainingPower1BonusFixed"`
+	SpecialTrainingPower2BonusFixed int             `json:"specialTrainingPower2BonusFixed"`
+	SpecialTrainingPower3BonusFixed int             `json:"specialTrainingPower3BonusFixed"`
+	Attr                            string          `json:"attr"`
+	SupportUnit                     string          `json:"supportUnit"`
+	SkillID                         int             `json:"skillId"`
+	CardSkillName                   string          `json:"cardSkillName"`
+	SpecialTrainingSkillID          int             `json:"specialTrainingSkillId,omitempty"`
+	SpecialTrainingSkillName        string          `json:"specialTrainingSkillName,omitempty"`
+	Prefix                          string          `json:"prefix"`
+	AssetbundleName                 string          `json:"assetbundleName"`
+	GachaPhrase                     string          `json:"gachaPhrase"`
+	ArchiveDisplayType              string          `json:"archiveDisplayType"`
+	ArchivePublishedAt              int64           `json:"archivePublishedAt"`
+	ReleaseAt                       int64           `json:"releaseAt"`
+	CardSupplyID                    int             `json:"cardSupplyId"`
+	CardParameters                  json.RawMessage `json:"cardParameters,omitempty"`
+}
+
+type CardSupply struct {
+	ID             int    `json:"id"`
+	CardSupplyType string `json:"cardSupplyType"`
+}
+
+type Skill struct {
+	ID                    int    `json:"id"`
+	ShortDescription      string `json:"shortDescription"`
+	Description           string `json:"description"`
+	DescriptionSpriteName string `json:"descriptionSpriteName"`
+}
+
 // Response Structs
 type GachaListItem struct {
 	ID              int    `json:"id"`
@@ -110,3 +173,149 @@ type GachaDetailResponse struct {
 	Gacha
 	PickupCardIds []int `json:"pickupCardIds"`
 }
+
+type CardListItem struct {
+	Card
+	CardSupplyType string      `json:"cardSupplyType"`
+	Event          *EventInfo  `json:"event"`
+	Gachas         []GachaInfo `json:"gachas"`
+}
+
+type CardListResponse struct {
+	Total int            `json:"total"`
+	Page  int            `json:"page"`
+	Limit int            `json:"limit"`
+	Cards []CardListItem `json:"cards"`
+}
+
+type CardDetailResponse struct {
+	CardListItem
+	Skill                *Skill `json:"skill"`
+	SpecialTrainingSkill *Skill `json:"specialTrainingSkill,omitempty"`
+}
+
+// Music types
+
+type Music struct {
+	ID                                int      `json:"id"`
+	Seq                               int      `json:"seq"`
+	ReleaseConditionID                int      `json:"releaseConditionId"`
+	Categories                        []string `json:"categories"`
+	Title                             string   `json:"title"`
+	Pronunciation                     string   `json:"pronunciation"`
+	CreatorArtistID                   int      `json:"creatorArtistId"`
+	Lyricist                          string   `json:"lyricist"`
+	Composer                          string   `json:"composer"`
+	Arranger                          string   `json:"arranger"`
+	DancerCount                       int      `json:"dancerCount"`
+	SelfDancerPosition                int      `json:"selfDancerPosition"`
+	AssetbundleName                   string   `json:"assetbundleName"`
+	LiveTalkBackgroundAssetbundleName string   `json:"liveTalkBackgroundAssetbundleName"`
+	PublishedAt                       int64    `json:"publishedAt"`
+	ReleasedAt                        int64    `json:"releasedAt"`
+	LiveStageID                       int      `json:"liveStageId"`
+	FillerSec                         float64  `json:"fillerSec"`
+	IsNewlyWrittenMusic               bool     `json:"isNewlyWrittenMusic"`
+	IsFullLength                      bool     `json:"isFullLength"`
+}
+
+type MusicTag struct {
+	ID       int    `json:"id"`
+	MusicID  int    `json:"musicId"`
+	MusicTag string `json:"musicTag"`
+	Seq      int    `json:"seq"`
+}
+
+type MusicDifficulty struct {
+	ID              int    `json:"id"`
+	MusicID         int    `json:"musicId"`
+	MusicDifficulty string `json:"musicDifficulty"`
+	PlayLevel       int    `json:"playLevel"`
+	TotalNoteCount  int    `json:"totalNoteCount"`
+}
+
+type MusicVocal struct {
+	ID                 int                   `json:"id"`
+	MusicID            int                   `json:"musicId"`
+	MusicVocalType     string                `json:"musicVocalType"`
+	Seq                int                   `json:"seq"`
+	ReleaseConditionID int                   `json:"releaseConditionId"`
+	Caption            string                `json:"caption"`
+	Characters         []MusicVocalCharacter `json:"characters"`
+	AssetbundleName    string                `json:"assetbundleName"`
+	ArchiveDisplayType string                `json:"archiveDisplayType"`
+	ArchivePublishedAt int64                 `json:"archivePublishedAt"`
+}
+
+type MusicVocalCharacter struct {
+	ID            int    `json:"id"`
+	MusicVocalID  int    `json:"musicVocalId"`
+	CharacterType string `json:"characterType"`
+	CharacterID   int    `json:"characterId"`
+	Seq           int    `json:"seq"`
+}
+
+type EventListItem struct {
+	Event
+	Status string `json:"status"`
+}
+
+type EventListResponse struct {
+	Total      int             `json:"total"`
+	Page       int             `json:"page"`
+	Limit      int             `json:"limit"`
+	ServerTime int64           `json:"serverTime"`
+	Events     []EventListItem `json:"events"`
+}
+
+type EventBonusCharacter struct {
+	GameCharacterUnitID int     `json:"gameCharacterUnitId"`
+	GameCharacterID     int     `json:"gameCharacterId"`
+	Unit                string  `json:"unit"`
+	BonusRate           float64 `json:"bonusRate"`
+}
+
+type EventDetailResponse struct {
+	EventListItem
+	ServerTime      int64                 `json:"serverTime"`
+	CardIds         []int                 `json:"cardIds"`
+	Cards           []Card                `json:"cards"`
+	MusicIds        []int                 `json:"musicIds"`
+	VirtualLive     *VirtualLiveInfo      `json:"virtualLive"`
+	BonusCharacters []EventBonusCharacter `json:"bonusCharacters"`
+	BonusAttributes []string              `json:"bonusAttributes"`
+	DeckBonuses     []EventDeckBonus      `json:"deckBonuses"`
+}
+
+type MusicListItem struct {
+	Music
+	Tags         []string          `json:"tags"`
+	Difficulties []MusicDifficulty `json:"difficulties"`
+	Events       []EventInfo       `json:"events"`
+}
+
+type MusicListResponse struct {
+	Total  int             `json:"total"`
+	Page   int             `json:"page"`
+	Limit  int             `json:"limit"`
+	Musics []MusicListItem `json:"musics"`
+}
+
+type MusicDetailResponse struct {
+	MusicListItem
+	Vocals []MusicVocal `json:"vocals"`
+}
+
+type SearchResult struct {
+	Type            string `json:"type"`
+	ID              int    `json:"id"`
+	Title           string `json:"title"`
+	AssetbundleName string `json:"assetbundleName"`
+	Score           int    `json:"score"`
+}
+
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Total   int            `json:"total"`
+	Results []SearchResult `json:"results"`
+}
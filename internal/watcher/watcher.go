@@ -0,0 +1,275 @@
+// Package watcher polls Bilibili feeds and notifies webhooks of new posts
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"snowy_viewer/internal/bilibili"
+	"snowy_viewer/internal/cache"
+)
+
+// lastSeenTTL keeps the last seen id of a feed. It is renewed on every
+// poll; a feed whose id expired is picked up again without notifying.
+const lastSeenTTL = 30 * 24 * time.Hour
+
+// Delivery defaults
+const (
+	DefaultMaxAttempts = 5
+	DefaultRetryDelay  = 2 * time.Second
+	maxRetryDelay      = 5 * time.Minute
+)
+
+// FeedSource fetches the newest page of a user's feed, as
+// bilibili.Client does
+type FeedSource interface {
+	FetchDynamic(uid string) ([]byte, int, error)
+}
+
+// Options configures a Watcher
+type Options struct {
+	UIDs     []string
+	Interval time.Duration
+	Webhooks []*Webhook
+	// MaxAttempts is the number of tries per delivery
+	MaxAttempts int
+	// RetryDelay is the wait before the first retry; it doubles after
+	// every attempt
+	RetryDelay time.Duration
+}
+
+// Watcher polls the feeds of UIDs and posts new dynamics to webhooks. The
+// newest dynamic id seen per UID is kept in the cache, so posts are not
+// sent twice across restarts when the cache is persistent.
+type Watcher struct {
+	feeds      FeedSource
+	cache      *cache.Cache
+	opts       Options
+	httpClient *http.Client
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// New creates a watcher. It fails when a webhook template does not parse.
+func New(feeds FeedSource, c *cache.Cache, opts Options) (*Watcher, error) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultRetryDelay
+	}
+	for _, hook := range opts.Webhooks {
+		if hook.URL == "" {
+			return nil, errors.New("webhook without url")
+		}
+		if err := hook.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return &Watcher{
+		feeds:      feeds,
+		cache:      c,
+		opts:       opts,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		stop:       make(chan struct{}),
+	}, nil
+}
+
+// Start polls every interval in the background until Stop
+func (w *Watcher) Start() {
+	fmt.Printf("Watching %d Bilibili feeds for %d webhooks every %s\n", len(w.opts.UIDs), len(w.opts.Webhooks), w.opts.Interval)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.opts.Interval)
+		defer ticker.Stop()
+		for {
+			w.Poll()
+			select {
+			case <-ticker.C:
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends polling and abandons pending retries
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+	w.wg.Wait()
+}
+
+// Poll checks every feed once
+func (w *Watcher) Poll() {
+	for _, uid := range w.opts.UIDs {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+		if err := w.check(uid); err != nil {
+			fmt.Printf("Watcher %s: %v\n", uid, err)
+		}
+	}
+}
+
+// check sends the dynamics of uid newer than the last seen one, oldest
+// first. The first check of a feed only records where it stands. The last
+// seen id advances past a dynamic only once every webhook received it or
+// failed permanently, so a delivery that ran out of retries is sent again
+// by the next poll, to the webhooks that already received it as well.
+func (w *Watcher) check(uid string) error {
+	data, status, err := w.feeds.FetchDynamic(uid)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("Bilibili API status %d", status)
+	}
+	feed, err := bilibili.ParseFeed(data)
+	if err != nil {
+		return err
+	}
+	if len(feed.Items) == 0 {
+		return nil
+	}
+
+	key := "watch:last:" + uid
+	raw, seen := w.cache.Get(key)
+	last := string(raw)
+
+	var fresh []bilibili.Dynamic
+	newest := last
+	for _, d := range feed.Items {
+		if newerID(d.ID, newest) {
+			newest = d.ID
+		}
+		if seen && newerID(d.ID, last) {
+			fresh = append(fresh, d)
+		}
+	}
+	if !seen {
+		if err := w.cache.Set(key, []byte(newest), lastSeenTTL); err != nil {
+			return err
+		}
+		fmt.Printf("Watcher %s: starting after dynamic %s\n", uid, newest)
+		return nil
+	}
+
+	sort.Slice(fresh, func(i, j int) bool {
+		return newerID(fresh[j].ID, fresh[i].ID)
+	})
+	for _, d := range fresh {
+		if err := w.notify(uid, d); err != nil {
+			return fmt.Errorf("dynamic %s: %v", d.ID, err)
+		}
+		last = d.ID
+		w.saveLast(key, last)
+	}
+	if len(fresh) == 0 {
+		w.saveLast(key, last)
+	}
+	return nil
+}
+
+// saveLast records the last seen id. A failed write is only logged: the
+// memory cache still holds the id, and at worst a dynamic is sent twice.
+func (w *Watcher) saveLast(key, id string) {
+	if err := w.cache.Set(key, []byte(id), lastSeenTTL); err != nil {
+		fmt.Printf("Watcher: failed to save %s: %v\n", key, err)
+	}
+}
+
+// newerID reports whether dynamic id a is newer than b. Ids are numeric
+// strings that grow over time.
+func newerID(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
+
+// notify delivers a dynamic to every interested webhook concurrently. It
+// returns the first failure that is worth another try; permanent failures
+// are logged and given up.
+func (w *Watcher) notify(uid string, d bilibili.Dynamic) error {
+	event := newEvent(uid, d)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		retryErr error
+	)
+	for _, hook := range w.opts.Webhooks {
+		if !hook.wants(uid, d) {
+			continue
+		}
+		body, err := hook.render(event)
+		if err != nil {
+			fmt.Printf("Webhook %s: %v\n", hook.URL, err)
+			continue
+		}
+		wg.Add(1)
+		go func(hook *Webhook) {
+			defer wg.Done()
+			err := w.deliver(hook, d.ID, body)
+			if err == nil {
+				return
+			}
+			var dErr *deliveryError
+			if errors.As(err, &dErr) && dErr.permanent {
+				fmt.Printf("Webhook %s: giving up on dynamic %s: %v\n", hook.URL, d.ID, err)
+				return
+			}
+			mu.Lock()
+			if retryErr == nil {
+				retryErr = fmt.Errorf("webhook %s: %v", hook.URL, err)
+			}
+			mu.Unlock()
+		}(hook)
+	}
+	wg.Wait()
+	return retryErr
+}
+
+// deliver posts body, retrying failed attempts with exponential backoff
+func (w *Watcher) deliver(hook *Webhook, id string, body []byte) error {
+	delay := w.opts.RetryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = hook.post(w.httpClient, id, body)
+		if err == nil {
+			return nil
+		}
+		var dErr *deliveryError
+		if errors.As(err, &dErr) && dErr.permanent {
+			return err
+		}
+		if attempt == w.opts.MaxAttempts {
+			return err
+		}
+
+		wait := delay
+		if dErr != nil && dErr.retryAfter > wait {
+			wait = dErr.retryAfter
+		}
+		if wait > maxRetryDelay {
+			wait = maxRetryDelay
+		}
+		fmt.Printf("Webhook %s: attempt %d failed (%v), retrying in %s\n", hook.URL, attempt, err, wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-w.stop:
+			timer.Stop()
+			return err
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,403 @@
+package watcher
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"snowy_viewer/internal/cache"
+)
+
+// fakeFeeds serves feed/space responses listing the given dynamic ids
+type fakeFeeds struct {
+	mu  sync.Mutex
+	ids map[string][]string
+}
+
+func (f *fakeFeeds) set(uid string, ids ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.ids == nil {
+		f.ids = make(map[string][]string)
+	}
+	f.ids[uid] = ids
+}
+
+func (f *fakeFeeds) FetchDynamic(uid string) ([]byte, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	items := make([]string, 0, len(f.ids[uid]))
+	for _, id := range f.ids[uid] {
+		items = append(items, fmt.Sprintf(`{
+			"id_str": %q,
+			"type": "DYNAMIC_TYPE_WORD",
+			"modules": {
+				"module_author": {"mid": %s, "name": "PJSK", "pub_ts": 1700000000},
+				"module_dynamic": {"desc": {"text": "post %s", "rich_text_nodes": []}}
+			}
+		}`, id, uid, id))
+	}
+	body := `{"code": 0, "data": {"has_more": false, "offset": "", "items": [` + strings.Join(items, ",") + `]}}`
+	return []byte(body), http.StatusOK, nil
+}
+
+// delivery is a request received by a receiver
+type delivery struct {
+	header http.Header
+	body   []byte
+	at     time.Time
+}
+
+// receiver is a webhook endpoint answering with a scripted sequence of
+// responses, then 204
+type receiver struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	deliveries []delivery
+	responses  []func(w http.ResponseWriter)
+}
+
+func newReceiver(t *testing.T, responses ...func(w http.ResponseWriter)) *receiver {
+	rcv := &receiver{responses: responses}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		rcv.deliveries = append(rcv.deliveries, delivery{header: r.Header.Clone(), body: body, at: time.Now()})
+		var respond func(http.ResponseWriter)
+		if len(rcv.responses) > 0 {
+			respond, rcv.responses = rcv.responses[0], rcv.responses[1:]
+		}
+		rcv.mu.Unlock()
+		if respond == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		respond(w)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *receiver) received() []delivery {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]delivery(nil), rcv.deliveries...)
+}
+
+func status(code int) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) { w.WriteHeader(code) }
+}
+
+func newTestWatcher(t *testing.T, feeds FeedSource, opts Options) (*Watcher, *cache.Cache) {
+	t.Helper()
+	c := cache.New(cache.Options{})
+	t.Cleanup(func() { c.Close() })
+	if opts.RetryDelay == 0 {
+		opts.RetryDelay = 10 * time.Millisecond
+	}
+	w, err := New(feeds, c, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.Stop)
+	return w, c
+}
+
+func TestFirstPollOnlySeeds(t *testing.T) {
+	rcv := newReceiver(t)
+	feeds := &fakeFeeds{}
+	feeds.set("1", "100", "99")
+	w, c := newTestWatcher(t, feeds, Options{UIDs: []string{"1"}, Webhooks: []*Webhook{{URL: rcv.URL}}})
+
+	w.Poll()
+	if got := rcv.received(); len(got) != 0 {
+		t.Fatalf("first poll sent %d deliveries", len(got))
+	}
+	if last, _ := c.Get("watch:last:1"); string(last) != "100" {
+		t.Errorf("last seen = %q, want 100", last)
+	}
+
+	// Nothing new on the second poll either
+	w.Poll()
+	if got := rcv.received(); len(got) != 0 {
+		t.Fatalf("unchanged feed sent %d deliveries", len(got))
+	}
+}
+
+func TestNewPostsAreDeliveredOldestFirst(t *testing.T) {
+	rcv := newReceiver(t)
+	feeds := &fakeFeeds{}
+	feeds.set("1", "100", "99")
+	w, c := newTestWatcher(t, feeds, Options{UIDs: []string{"1"}, Webhooks: []*Webhook{{URL: rcv.URL}}})
+	w.Poll()
+
+	feeds.set("1", "102", "101", "100", "99")
+	w.Poll()
+	got := rcv.received()
+	if len(got) != 2 {
+		t.Fatalf("got %d deliveries, want 2", len(got))
+	}
+	for i, want := range []string{"101", "102"} {
+		if id := got[i].header.Get(HeaderDelivery); id != want {
+			t.Errorf("delivery %d is dynamic %s, want %s", i, id, want)
+		}
+		var payload struct {
+			UID    string `json:"uid"`
+			ID     string `json:"id"`
+			Type   string `json:"type"`
+			Author string `json:"author"`
+			Text   string `json:"text"`
+		}
+		if err := json.Unmarshal(got[i].body, &payload); err != nil {
+			t.Fatalf("delivery %d: %v\n%s", i, err, got[i].body)
+		}
+		if payload.UID != "1" || payload.ID != want || payload.Type != "text" || payload.Author != "PJSK" || payload.Text != "post "+want {
+			t.Errorf("delivery %d payload = %+v", i, payload)
+		}
+		if ct := got[i].header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if got[i].header.Get(HeaderSignature) != "" {
+			t.Error("unsigned webhook got a signature")
+		}
+	}
+	if last, _ := c.Get("watch:last:1"); string(last) != "102" {
+		t.Errorf("last seen = %q, want 102", last)
+	}
+
+	// A post dropping off the page is not a new post
+	feeds.set("1", "102", "101")
+	w.Poll()
+	if n := len(rcv.received()); n != 2 {
+		t.Errorf("got %d deliveries after an unchanged poll, want 2", n)
+	}
+}
+
+func TestFailedDeliveryIsRetriedByNextPoll(t *testing.T) {
+	// 101 is delivered, 102 fails every attempt of the first poll
+	rcv := newReceiver(t, nil, status(500), status(500))
+	feeds := &fakeFeeds{}
+	feeds.set("1", "100")
+	w, c := newTestWatcher(t, feeds, Options{UIDs: []string{"1"}, Webhooks: []*Webhook{{URL: rcv.URL}}, MaxAttempts: 2})
+	w.Poll()
+
+	feeds.set("1", "102", "101", "100")
+	w.Poll()
+	if last, _ := c.Get("watch:last:1"); string(last) != "101" {
+		t.Fatalf("last seen = %q, want 101", last)
+	}
+
+	w.Poll()
+	var ids []string
+	for _, d := range rcv.received() {
+		ids = append(ids, d.header.Get(HeaderDelivery))
+	}
+	if strings.Join(ids, ",") != "101,102,102,102" {
+		t.Errorf("deliveries = %v, want 101 then 102 three times", ids)
+	}
+	if last, _ := c.Get("watch:last:1"); string(last) != "102" {
+		t.Errorf("last seen = %q, want 102", last)
+	}
+}
+
+func TestPermanentFailureIsGivenUp(t *testing.T) {
+	rcv := newReceiver(t, status(http.StatusNotFound))
+	feeds := &fakeFeeds{}
+	feeds.set("1", "100")
+	w, c := newTestWatcher(t, feeds, Options{UIDs: []string{"1"}, Webhooks: []*Webhook{{URL: rcv.URL}}})
+	w.Poll()
+
+	feeds.set("1", "101", "100")
+	w.Poll()
+	w.Poll()
+	if n := len(rcv.received()); n != 1 {
+		t.Errorf("got %d deliveries, want 1", n)
+	}
+	if last, _ := c.Get("watch:last:1"); string(last) != "101" {
+		t.Errorf("last seen = %q, want 101", last)
+	}
+}
+
+func TestWebhookTemplateAndFilters(t *testing.T) {
+	all := newReceiver(t)
+	videos := newReceiver(t)
+	other := newReceiver(t)
+	feeds := &fakeFeeds{}
+	feeds.set("1", "99")
+	feeds.set("2", "99")
+	w, _ := newTestWatcher(t, feeds, Options{
+		UIDs: []string{"1", "2"},
+		Webhooks: []*Webhook{
+			{URL: all.URL, Template: `{"content": {{json (printf "%s: %s" .Author (truncate 3 .Text))}}}`},
+			{URL: videos.URL, Types: []string{"video"}},
+			{URL: other.URL, UIDs: []string{"2"}},
+		},
+	})
+	w.Poll()
+
+	feeds.set("1", "100", "99")
+	w.Poll()
+	got := all.received()
+	if len(got) != 1 {
+		t.Fatalf("got %d templated deliveries, want 1", len(got))
+	}
+	if string(got[0].body) != `{"content": "PJSK: pos…"}` {
+		t.Errorf("templated body = %s", got[0].body)
+	}
+	if n := len(videos.received()); n != 0 {
+		t.Errorf("type filter let %d deliveries through", n)
+	}
+	if n := len(other.received()); n != 0 {
+		t.Errorf("uid filter let %d deliveries through", n)
+	}
+}
+
+func TestNewRejectsInvalidWebhooks(t *testing.T) {
+	c := cache.New(cache.Options{})
+	defer c.Close()
+	for _, hook := range []*Webhook{
+		{},
+		{URL: "http://example.com", Template: "{{"},
+	} {
+		if _, err := New(&fakeFeeds{}, c, Options{Webhooks: []*Webhook{hook}}); err == nil {
+			t.Errorf("New accepted %+v", hook)
+		}
+	}
+
+	// Templates that do not produce JSON are caught when rendering
+	hook := &Webhook{URL: "http://example.com", Template: `{"text": {{.Text}}}`}
+	if err := hook.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hook.render(Event{Text: "not quoted"}); err == nil {
+		t.Error("render accepted invalid JSON")
+	}
+}
+
+func TestDeliveryRetriesServerErrorsWithBackoff(t *testing.T) {
+	rcv := newReceiver(t, status(http.StatusInternalServerError), status(http.StatusBadGateway))
+	w, _ := newTestWatcher(t, &fakeFeeds{}, Options{RetryDelay: 50 * time.Millisecond})
+
+	if err := w.deliver(&Webhook{URL: rcv.URL}, "1", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	got := rcv.received()
+	if len(got) != 3 {
+		t.Fatalf("got %d attempts, want 3", len(got))
+	}
+	// The delay doubles after every attempt
+	if gap := got[1].at.Sub(got[0].at); gap < 50*time.Millisecond {
+		t.Errorf("first retry after %s", gap)
+	}
+	if gap := got[2].at.Sub(got[1].at); gap < 100*time.Millisecond {
+		t.Errorf("second retry after %s", gap)
+	}
+}
+
+func TestDeliveryGivesUpAfterMaxAttempts(t *testing.T) {
+	rcv := newReceiver(t, status(500), status(500), status(500), status(500))
+	w, _ := newTestWatcher(t, &fakeFeeds{}, Options{MaxAttempts: 3})
+
+	err := w.deliver(&Webhook{URL: rcv.URL}, "1", []byte(`{}`))
+	var dErr *deliveryError
+	if !errors.As(err, &dErr) || dErr.status != http.StatusInternalServerError {
+		t.Fatalf("err = %v, want status 500", err)
+	}
+	if n := len(rcv.received()); n != 3 {
+		t.Errorf("got %d attempts, want 3", n)
+	}
+}
+
+func TestDeliveryHonoursRetryAfter(t *testing.T) {
+	rcv := newReceiver(t, func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	w, _ := newTestWatcher(t, &fakeFeeds{}, Options{})
+
+	if err := w.deliver(&Webhook{URL: rcv.URL}, "1", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	got := rcv.received()
+	if len(got) != 2 {
+		t.Fatalf("got %d attempts, want 2", len(got))
+	}
+	if gap := got[1].at.Sub(got[0].at); gap < time.Second {
+		t.Errorf("retried after %s despite Retry-After: 1", gap)
+	}
+}
+
+func TestDeliveryDoesNotRetryClientErrors(t *testing.T) {
+	for _, code := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound} {
+		rcv := newReceiver(t, status(code))
+		w, _ := newTestWatcher(t, &fakeFeeds{}, Options{})
+
+		err := w.deliver(&Webhook{URL: rcv.URL}, "1", []byte(`{}`))
+		var dErr *deliveryError
+		if !errors.As(err, &dErr) || !dErr.permanent || dErr.status != code {
+			t.Errorf("%d: err = %v, want permanent failure", code, err)
+		}
+		if n := len(rcv.received()); n != 1 {
+			t.Errorf("%d: got %d attempts, want 1", code, n)
+		}
+	}
+}
+
+func TestStopAbandonsRetries(t *testing.T) {
+	rcv := newReceiver(t, status(500))
+	w, _ := newTestWatcher(t, &fakeFeeds{}, Options{RetryDelay: time.Minute})
+
+	done := make(chan error, 1)
+	go func() { done <- w.deliver(&Webhook{URL: rcv.URL}, "1", []byte(`{}`)) }()
+	for len(rcv.received()) == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	w.Stop()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("abandoned delivery reported success")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not abandon the retry")
+	}
+}
+
+func TestSignature(t *testing.T) {
+	rcv := newReceiver(t)
+	w, _ := newTestWatcher(t, &fakeFeeds{}, Options{})
+	body := []byte(`{"id": "100"}`)
+
+	before := time.Now().Unix()
+	if err := w.deliver(&Webhook{URL: rcv.URL, Secret: "s3cret"}, "100", body); err != nil {
+		t.Fatal(err)
+	}
+	got := rcv.received()[0]
+
+	timestamp := got.header.Get(HeaderTimestamp)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || ts < before || ts > time.Now().Unix() {
+		t.Fatalf("timestamp = %q", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(timestamp + "." + string(got.body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if sig := got.header.Get(HeaderSignature); sig != want {
+		t.Errorf("signature = %q, want %q", sig, want)
+	}
+	if string(got.body) != string(body) {
+		t.Errorf("body = %s", got.body)
+	}
+}
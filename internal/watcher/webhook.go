@@ -0,0 +1,215 @@
+package watcher
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"snowy_viewer/internal/bilibili"
+)
+
+// DefaultTemplate renders a generic JSON payload
+const DefaultTemplate = `{
+  "uid": {{json .UID}},
+  "id": {{json .ID}},
+  "type": {{json .Type}},
+  "author": {{json .Author}},
+  "title": {{json .Title}},
+  "text": {{json .Text}},
+  "url": {{json .URL}},
+  "image": {{json .Image}},
+  "publishedAt": {{json .PublishedAt}},
+  "dynamic": {{json .Dynamic}}
+}`
+
+// Signature headers. The signature is the hex HMAC-SHA256 of
+// "{timestamp}.{body}" keyed with the webhook secret, so receivers can
+// reject replayed deliveries.
+const (
+	HeaderSignature = "X-Snowy-Signature"
+	HeaderTimestamp = "X-Snowy-Timestamp"
+	HeaderDelivery  = "X-Snowy-Delivery"
+)
+
+// Webhook is a receiver of new posts
+type Webhook struct {
+	URL string `json:"url"`
+	// Secret signs deliveries; unsigned when empty
+	Secret string `json:"secret"`
+	// Template is a text/template producing the JSON body, e.g.
+	// {"content": {{json .URL}}}; DefaultTemplate when empty
+	Template string `json:"template"`
+	// UIDs and Types limit the posts sent; empty sends everything
+	UIDs  []string `json:"uids"`
+	Types []string `json:"types"`
+
+	tmpl *template.Template
+}
+
+// Event is the data a webhook template is rendered with
+type Event struct {
+	UID         string
+	ID          string
+	Type        string
+	Author      string
+	Title       string
+	Text        string
+	URL         string
+	Image       string
+	PublishedAt time.Time
+	Dynamic     bilibili.Dynamic
+}
+
+func newEvent(uid string, d bilibili.Dynamic) Event {
+	return Event{
+		UID:         uid,
+		ID:          d.ID,
+		Type:        d.Type,
+		Author:      d.Author.Name,
+		Title:       d.Summary(),
+		Text:        d.Text,
+		URL:         d.URL,
+		Image:       d.CoverImage(),
+		PublishedAt: time.Unix(d.PublishedAt, 0).UTC(),
+		Dynamic:     d,
+	}
+}
+
+var templateFuncs = template.FuncMap{
+	// json encodes a value as a JSON literal, which keeps templates valid
+	// whatever the post contains
+	"json": func(v interface{}) (string, error) {
+		out, err := json.Marshal(v)
+		return string(out), err
+	},
+	"truncate": func(n int, s string) string {
+		if r := []rune(s); len(r) > n {
+			return string(r[:n]) + "…"
+		}
+		return s
+	},
+}
+
+// compile parses the template of w
+func (w *Webhook) compile() error {
+	text := w.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New(w.URL).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("webhook %s: %v", w.URL, err)
+	}
+	w.tmpl = tmpl
+	return nil
+}
+
+// wants reports whether a post of uid should be sent to w
+func (w *Webhook) wants(uid string, d bilibili.Dynamic) bool {
+	return (len(w.UIDs) == 0 || contains(w.UIDs, uid)) &&
+		(len(w.Types) == 0 || contains(w.Types, d.Type))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// render produces the body for an event
+func (w *Webhook) render(event Event) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, event); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("template of %s did not produce valid JSON", w.URL)
+	}
+	return buf.Bytes(), nil
+}
+
+// sign returns the signature of a delivery
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// LoadWebhooks reads a JSON array of webhooks from path
+func LoadWebhooks(path string) ([]*Webhook, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var hooks []*Webhook
+	if err := json.Unmarshal(content, &hooks); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	return hooks, nil
+}
+
+// deliveryError is a failed delivery; permanent failures are not retried
+type deliveryError struct {
+	status     int
+	permanent  bool
+	retryAfter time.Duration
+	err        error
+}
+
+func (e *deliveryError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("status %d", e.status)
+}
+
+// post sends one delivery attempt
+func (w *Webhook) post(client *http.Client, id string, body []byte) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return &deliveryError{permanent: true, err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "snowy-viewer-webhook")
+	req.Header.Set(HeaderDelivery, id)
+	if w.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(HeaderTimestamp, timestamp)
+		req.Header.Set(HeaderSignature, sign(w.Secret, timestamp, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return &deliveryError{err: err}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		dErr := &deliveryError{status: resp.StatusCode}
+		if seconds, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get("Retry-After"))); err == nil && seconds > 0 {
+			dErr.retryAfter = time.Duration(seconds) * time.Second
+		}
+		return dErr
+	default:
+		return &deliveryError{status: resp.StatusCode, permanent: true}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"snowy_viewer/internal/handlers"
 	"snowy_viewer/internal/masterdata"
 	"snowy_viewer/internal/middleware"
+	"snowy_viewer/internal/watcher"
 )
 
 func main() {
@@ -18,23 +19,77 @@ func main() {
 	cfg := config.Load()
 
 	// Initialize cache (Redis with memory fallback)
-	appCache := cache.New(cfg.RedisURL)
+	appCache := cache.New(cache.Options{
+		RedisURL:       cfg.RedisURL,
+		MemoryMaxBytes: int64(cfg.CacheMemoryMB) << 20,
+		Dir:            cfg.CacheDir,
+		ImageDir:       cfg.ImageCacheDir,
+		ImageMaxBytes:  int64(cfg.ImageCacheMB) << 20,
+	})
 	defer appCache.Close()
 
 	// Initialize Bilibili client
-	biliClient := bilibili.NewClient(appCache, cfg.BilibiliSessData, cfg.BilibiliCookie)
+	biliClient := bilibili.NewClient(appCache, bilibili.Options{
+		SessData:        cfg.BilibiliSessData,
+		CookieString:    cfg.BilibiliCookie,
+		CredentialsFile: cfg.BilibiliCredentials,
+		ImagePolicy: bilibili.ImagePolicy{
+			AllowedHosts: cfg.ImageProxyHosts,
+			MaxBytes:     int64(cfg.ImageProxyMaxMB) << 20,
+		},
+		ArchiveDir:   cfg.BilibiliArchiveDir,
+		TimelineUIDs: cfg.BilibiliTimelineUIDs,
+		Limits: bilibili.Limits{
+			RatePerMinute:    cfg.BilibiliRateLimit,
+			Burst:            cfg.BilibiliRateBurst,
+			FailureThreshold: cfg.BilibiliBreakerFails,
+			Cooldown:         cfg.BilibiliBreakerOpen,
+		},
+	})
 
-	// Initialize and load master data
-	store := masterdata.NewStore(cfg.MasterDataPath)
-	if err := store.Fetch(); err != nil {
-		fmt.Printf("Initial fetch error: %v\n", err)
+	// Notify webhooks of new posts
+	if len(cfg.WatchUIDs) > 0 {
+		if w := startWatcher(cfg, biliClient, appCache); w != nil {
+			defer w.Stop()
+		}
 	}
 
+	// Initialize and load master data for every configured server
+	masterdata.FetchRetries = cfg.MasterFetchRetries
+	if cfg.MasterSourcesFile != "" {
+		if err := masterdata.LoadSourceOverrides(cfg.MasterSourcesFile); err != nil {
+			fmt.Printf("Warning: master data sources not loaded: %v\n", err)
+		}
+	}
+	stores := masterdata.NewRegistry(cfg.MasterServers, cfg.MasterDataPath)
+	for server, err := range stores.Fetch() {
+		fmt.Printf("[%s] Initial fetch error: %v\n", server, err)
+	}
+	stores.StartPeriodicUpdate(cfg.MasterUpdateInterval)
+
 	// Create router and register handlers
 	mux := http.NewServeMux()
-	handler := handlers.New(store, biliClient)
+	handler := handlers.New(stores, biliClient, appCache)
+	handler.SetPublicBaseURL(cfg.PublicBaseURL)
 	handler.RegisterRoutes(mux)
 
+	// Admin routes, either on their own listener or under /admin/
+	if cfg.AdminToken != "" {
+		adminMux := http.NewServeMux()
+		handler.RegisterAdminRoutes(adminMux)
+		adminHandler := middleware.Chain(adminMux, middleware.RequireToken(cfg.AdminToken))
+		if cfg.AdminPort != "" {
+			go func() {
+				fmt.Printf("Admin server starting on :%s...\n", cfg.AdminPort)
+				if err := http.ListenAndServe(":"+cfg.AdminPort, adminHandler); err != nil {
+					fmt.Printf("Error starting admin server: %s\n", err)
+				}
+			}()
+		} else {
+			mux.Handle("/admin/", adminHandler)
+		}
+	}
+
 	// Static file serving
 	if _, err := os.Stat("./dist"); !os.IsNotExist(err) {
 		fmt.Println("Serving static files from ./dist")
@@ -58,3 +113,35 @@ func main() {
 		fmt.Printf("Error starting server: %s\n", err)
 	}
 }
+
+// startWatcher starts polling the watched UIDs for the webhooks of
+// WEBHOOKS_FILE and WEBHOOK_URLS
+func startWatcher(cfg *config.Config, biliClient *bilibili.Client, appCache *cache.Cache) *watcher.Watcher {
+	var hooks []*watcher.Webhook
+	if cfg.WebhooksFile != "" {
+		loaded, err := watcher.LoadWebhooks(cfg.WebhooksFile)
+		if err != nil {
+			fmt.Printf("Warning: webhooks not loaded: %v\n", err)
+		}
+		hooks = append(hooks, loaded...)
+	}
+	for _, url := range cfg.WebhookURLs {
+		hooks = append(hooks, &watcher.Webhook{URL: url, Secret: cfg.WebhookSecret, Template: cfg.WebhookTemplate})
+	}
+	if len(hooks) == 0 {
+		fmt.Println("Warning: WATCH_UIDS is set but no webhooks are configured")
+		return nil
+	}
+
+	w, err := watcher.New(biliClient, appCache, watcher.Options{
+		UIDs:     cfg.WatchUIDs,
+		Interval: cfg.WatchInterval,
+		Webhooks: hooks,
+	})
+	if err != nil {
+		fmt.Printf("Warning: watcher not started: %v\n", err)
+		return nil
+	}
+	w.Start()
+	return w
+}